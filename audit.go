@@ -0,0 +1,307 @@
+package logger
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/spf13/pflag"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	flagAuditOutputPaths = "audit.output-paths"
+
+	auditSeqKey  = "seq"
+	auditPrevKey = "prev"
+	auditHashKey = "hash"
+
+	// maxAuditRecord bounds the length of a record read back from a file.
+	maxAuditRecord = 1 << 20
+)
+
+// ErrAuditTampered is wrapped by the errors of VerifyAudit.
+var ErrAuditTampered = errors.New("audit log tampered")
+
+// AuditOptions configures an AuditLogger.
+type AuditOptions struct {
+	// OutputPaths are zap output paths. When the first one is an existing
+	// file the sequence and the chain continue from its last record.
+	OutputPaths []string `json:"output-paths" yaml:"output-paths" mapstructure:"output-paths"`
+	// Key is the HMAC key of the chain, an empty key chains with plain
+	// SHA-256 which detects edits but not forged records.
+	Key string `json:"key" yaml:"key" mapstructure:"key"`
+}
+
+func NewAuditOptions() *AuditOptions {
+	return &AuditOptions{
+		OutputPaths: []string{"audit.log"},
+	}
+}
+
+// Validate checks that the audit log has an output.
+func (o *AuditOptions) Validate() []error {
+	var errs []error
+	if len(o.OutputPaths) == 0 {
+		errs = append(errs, fmt.Errorf("audit log requires an output path"))
+	}
+	return errs
+}
+
+func (o *AuditOptions) AddFlags(fs *pflag.FlagSet) {
+	fs.StringSliceVar(&o.OutputPaths, flagAuditOutputPaths, o.OutputPaths, "Output paths of the audit log.")
+}
+
+// AuditLogger writes audit records, separately from the application logs.
+// It never samples, deduplicates or drops a record: every record is written
+// synchronously and a failed write is returned to the caller. Each record
+// carries a sequence number, the hash of the previous record and its own
+// hash over both, so that VerifyAudit detects missing and edited records.
+type AuditLogger struct {
+	mu    sync.Mutex
+	enc   zapcore.Encoder
+	out   zapcore.WriteSyncer
+	close func()
+	key   []byte
+	seq   uint64
+	prev  string
+}
+
+// NewAuditLogger opens the outputs of the audit log.
+func NewAuditLogger(opts *AuditOptions) (*AuditLogger, error) {
+	if errs := opts.Validate(); len(errs) > 0 {
+		return nil, errs[0]
+	}
+	a := &AuditLogger{
+		enc: zapcore.NewJSONEncoder(zapcore.EncoderConfig{
+			TimeKey:        "timestamp",
+			MessageKey:     "message",
+			EncodeTime:     zapcore.RFC3339NanoTimeEncoder,
+			EncodeDuration: zapcore.StringDurationEncoder,
+		}),
+		key: []byte(opts.Key),
+	}
+	seq, prev, err := lastAuditRecord(opts.OutputPaths[0])
+	if err != nil {
+		return nil, err
+	}
+	out, closeOut, err := zap.Open(opts.OutputPaths...)
+	if err != nil {
+		return nil, err
+	}
+	a.out, a.close, a.seq, a.prev = out, closeOut, seq, prev
+	return a, nil
+}
+
+// Log writes a record of msg with the given key-value pairs, which may also
+// be Fields.
+func (a *AuditLogger) Log(msg string, keysAndValues ...interface{}) error {
+	fields, err := auditFields(keysAndValues)
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	seq := a.seq + 1
+	fields = append(fields, zap.Uint64(auditSeqKey, seq), zap.String(auditPrevKey, a.prev))
+	buf, err := a.enc.EncodeEntry(Entry{Time: time.Now().UTC(), Message: msg}, fields)
+	if err != nil {
+		return err
+	}
+	defer buf.Free()
+
+	body := bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+	sum := auditHash(a.key, body)
+	record := make([]byte, 0, len(body)+len(sum)+12)
+	record = append(record, body[:len(body)-1]...)
+	record = append(record, `,"`+auditHashKey+`":"`+sum+`"}`+"\n"...)
+	if _, err := a.out.Write(record); err != nil {
+		return err
+	}
+	if err := a.out.Sync(); err != nil && !isIgnorableSyncError(err) {
+		return err
+	}
+	a.seq, a.prev = seq, sum
+	return nil
+}
+
+// Sync flushes the outputs of the audit log.
+func (a *AuditLogger) Sync() error {
+	return a.out.Sync()
+}
+
+// Close closes the outputs of the audit log.
+func (a *AuditLogger) Close() error {
+	err := a.Sync()
+	a.close()
+	return err
+}
+
+// isIgnorableSyncError reports whether err comes from syncing an output that
+// cannot be synced, such as a terminal or a pipe.
+func isIgnorableSyncError(err error) bool {
+	return errors.Is(err, syscall.EINVAL) || errors.Is(err, syscall.ENOTTY)
+}
+
+func auditFields(keysAndValues []interface{}) ([]Field, error) {
+	fields := make([]Field, 0, len(keysAndValues)/2+2)
+	for i := 0; i < len(keysAndValues); {
+		if f, ok := keysAndValues[i].(Field); ok {
+			if err := checkAuditKey(f.Key); err != nil {
+				return nil, err
+			}
+			fields = append(fields, f)
+			i++
+			continue
+		}
+		if i == len(keysAndValues)-1 {
+			return nil, fmt.Errorf("odd number of audit key-value pairs, ignored key %v", keysAndValues[i])
+		}
+		key, ok := keysAndValues[i].(string)
+		if !ok {
+			return nil, fmt.Errorf("non-string audit key %v", keysAndValues[i])
+		}
+		if err := checkAuditKey(key); err != nil {
+			return nil, err
+		}
+		fields = append(fields, zap.Any(key, keysAndValues[i+1]))
+		i += 2
+	}
+	return fields, nil
+}
+
+// checkAuditKey rejects the keys of the chain fields written by the audit
+// logger.
+func checkAuditKey(key string) error {
+	switch key {
+	case auditSeqKey, auditPrevKey, auditHashKey:
+		return fmt.Errorf("audit key %q is reserved", key)
+	}
+	return nil
+}
+
+// auditHash returns the HMAC-SHA256 of a record without its hash, or its
+// SHA-256 without a key. The record holds the previous hash, which chains it.
+func auditHash(key, body []byte) string {
+	var h hash.Hash
+	if len(key) > 0 {
+		h = hmac.New(sha256.New, key)
+	} else {
+		h = sha256.New()
+	}
+	_, _ = h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// splitAuditRecord returns the record without its hash, as it was hashed,
+// and the hash.
+func splitAuditRecord(line []byte) ([]byte, string, bool) {
+	const hexLen = sha256.Size * 2
+	suffix := len(`,"`+auditHashKey+`":"`) + hexLen + len(`"}`)
+	if len(line) < suffix+1 {
+		return nil, "", false
+	}
+	tail := line[len(line)-suffix:]
+	if !bytes.HasPrefix(tail, []byte(`,"`+auditHashKey+`":"`)) || !bytes.HasSuffix(tail, []byte(`"}`)) {
+		return nil, "", false
+	}
+	body := make([]byte, 0, len(line)-suffix+1)
+	body = append(body, line[:len(line)-suffix]...)
+	body = append(body, '}')
+	return body, string(tail[len(tail)-hexLen-2 : len(tail)-2]), true
+}
+
+type auditChain struct {
+	Seq  uint64 `json:"seq"`
+	Prev string `json:"prev"`
+}
+
+// VerifyAudit reads an audit log written by an AuditLogger with key and
+// checks that the records are complete, in order and unmodified. The error
+// names the first broken record and wraps ErrAuditTampered.
+func VerifyAudit(r io.Reader, key string) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxAuditRecord)
+	var seq uint64
+	var prev string
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		body, sum, ok := splitAuditRecord(scanner.Bytes())
+		if !ok {
+			return fmt.Errorf("%w: line %d: malformed record", ErrAuditTampered, line)
+		}
+		var chain auditChain
+		if err := json.Unmarshal(body, &chain); err != nil {
+			return fmt.Errorf("%w: line %d: %v", ErrAuditTampered, line, err)
+		}
+		if chain.Seq != seq+1 {
+			return fmt.Errorf("%w: line %d: expected sequence %d, got %d", ErrAuditTampered, line, seq+1, chain.Seq)
+		}
+		if chain.Prev != prev {
+			return fmt.Errorf("%w: line %d: broken chain at sequence %d", ErrAuditTampered, line, chain.Seq)
+		}
+		if !hmac.Equal([]byte(auditHash([]byte(key), body)), []byte(sum)) {
+			return fmt.Errorf("%w: line %d: hash mismatch at sequence %d", ErrAuditTampered, line, chain.Seq)
+		}
+		seq, prev = chain.Seq, sum
+	}
+	return scanner.Err()
+}
+
+// lastAuditRecord returns the sequence and hash of the last record of the
+// audit file at path, or zero values when it is not an existing file.
+func lastAuditRecord(path string) (uint64, string, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return 0, "", nil
+	}
+	if err != nil {
+		return 0, "", err
+	}
+	defer f.Close()
+	if info, err := f.Stat(); err != nil || !info.Mode().IsRegular() {
+		return 0, "", nil
+	}
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxAuditRecord)
+	var last []byte
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) > 0 {
+			last = append(last[:0], scanner.Bytes()...)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, "", err
+	}
+	if last == nil {
+		return 0, "", nil
+	}
+	body, sum, ok := splitAuditRecord(last)
+	if !ok {
+		return 0, "", fmt.Errorf("audit log %s: last record is malformed", path)
+	}
+	var chain auditChain
+	if err := json.Unmarshal(body, &chain); err != nil {
+		return 0, "", fmt.Errorf("audit log %s: %w", path, err)
+	}
+	return chain.Seq, sum, nil
+}
@@ -0,0 +1,223 @@
+package logger
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap/zapcore"
+)
+
+var benchPaths = []string{"/api/v1/users", "/api/v1/orders", "/healthz"}
+
+// stubSpan is a recording span without an SDK, enough for the logger to add
+// span events and trace ids.
+type stubSpan struct {
+	trace.Span
+	sc trace.SpanContext
+}
+
+func (s stubSpan) IsRecording() bool              { return true }
+func (s stubSpan) SpanContext() trace.SpanContext { return s.sc }
+
+func recordingContext() context.Context {
+	span := stubSpan{
+		Span: trace.SpanFromContext(context.Background()),
+		sc: trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID:    trace.TraceID{1},
+			SpanID:     trace.SpanID{1},
+			TraceFlags: trace.FlagsSampled,
+		}),
+	}
+	return trace.ContextWithSpan(context.Background(), span)
+}
+
+// benchCase is one way of logging an entry. The same cases are benchmarked
+// and held to their allocation budget by TestAllocationBudget.
+type benchCase struct {
+	name   string
+	format string
+	// budget is the number of allocations per entry allowed. Raise it only
+	// together with the change that needs it.
+	budget float64
+	// setup returns the function logging the i-th entry.
+	setup func(l *logger) func(i int)
+}
+
+var benchCases = []benchCase{
+	{
+		name:   "disabled/sugared",
+		budget: 1,
+		setup: func(l *logger) func(i int) {
+			return func(i int) {
+				l.Debugw("request served", "path", benchPaths[i%len(benchPaths)], "status", 200+i%5)
+			}
+		},
+	},
+	{
+		name:   "disabled/typed",
+		budget: 1,
+		setup: func(l *logger) func(i int) {
+			typed := l.Desugared()
+			return func(i int) {
+				typed.Debug("request served", String("path", benchPaths[i%len(benchPaths)]), Int("status", 200+i%5))
+			}
+		},
+	},
+	{
+		name:   "disabled/v",
+		budget: 2,
+		setup: func(l *logger) func(i int) {
+			return func(i int) {
+				l.V(zapcore.DebugLevel).Infow("request served", "path", benchPaths[i%len(benchPaths)])
+			}
+		},
+	},
+	{
+		name:   "json/sugared",
+		budget: 5,
+		setup: func(l *logger) func(i int) {
+			return func(i int) {
+				l.Infow("request served", "path", benchPaths[i%len(benchPaths)], "status", 200+i%5, "elapsed", time.Duration(i))
+			}
+		},
+	},
+	{
+		name:   "json/typed",
+		budget: 4,
+		setup: func(l *logger) func(i int) {
+			typed := l.Desugared()
+			return func(i int) {
+				typed.Info("request served",
+					String("path", benchPaths[i%len(benchPaths)]), Int("status", 200+i%5), Duration("elapsed", time.Duration(i)))
+			}
+		},
+	},
+	{
+		name:   "console/sugared",
+		format: consoleFormat,
+		budget: 8,
+		setup: func(l *logger) func(i int) {
+			return func(i int) {
+				l.Infow("request served", "path", benchPaths[i%len(benchPaths)], "status", 200+i%5, "elapsed", time.Duration(i))
+			}
+		},
+	},
+	{
+		name:   "console/typed",
+		format: consoleFormat,
+		budget: 7,
+		setup: func(l *logger) func(i int) {
+			typed := l.Desugared()
+			return func(i int) {
+				typed.Info("request served",
+					String("path", benchPaths[i%len(benchPaths)]), Int("status", 200+i%5), Duration("elapsed", time.Duration(i)))
+			}
+		},
+	},
+	{
+		name:   "with",
+		budget: 15,
+		setup: func(l *logger) func(i int) {
+			return func(i int) {
+				l.With("path", benchPaths[i%len(benchPaths)]).Infow("request served")
+			}
+		},
+	},
+	{
+		name:   "with-caller-skip",
+		budget: 19,
+		setup: func(l *logger) func(i int) {
+			return func(i int) {
+				l.WithCallerSkip(l.ctx, 1, l.tracing, "path", benchPaths[i%len(benchPaths)]).Infow("request served")
+			}
+		},
+	},
+	{
+		name:   "ctx/no-span",
+		budget: 6,
+		setup: func(l *logger) func(i int) {
+			bound := l.Ctx(context.Background())
+			return func(i int) {
+				bound.Infow("request served", "path", benchPaths[i%len(benchPaths)], "status", 200+i%5)
+			}
+		},
+	},
+	{
+		name:   "ctx/recording-span",
+		budget: 15,
+		setup: func(l *logger) func(i int) {
+			bound := l.Ctx(recordingContext())
+			return func(i int) {
+				bound.Infow("request served", "path", benchPaths[i%len(benchPaths)], "status", 200+i%5)
+			}
+		},
+	},
+	{
+		name:   "ctx/recording-span-typed",
+		budget: 11,
+		setup: func(l *logger) func(i int) {
+			typed := l.Ctx(recordingContext()).Desugared()
+			return func(i int) {
+				typed.Info("request served", String("path", benchPaths[i%len(benchPaths)]), Int("status", 200+i%5))
+			}
+		},
+	},
+	{
+		name:   "handle-fields",
+		budget: 2,
+		setup: func(l *logger) func(i int) {
+			return func(i int) {
+				_ = handleFields(l.zapLogger, []interface{}{"path", benchPaths[i%len(benchPaths)], "elapsed", time.Duration(i)})
+			}
+		},
+	},
+}
+
+// newBenchLogger returns an info logger writing every entry to a file, without
+// sampling, so that the cases measure the encoding of each entry.
+func newBenchLogger(tb testing.TB, format string) *logger {
+	opts := NewOptions()
+	opts.OutputPaths = []string{filepath.Join(tb.TempDir(), "bench.log")}
+	opts.DisableSampling = true
+	if format != "" {
+		opts.Format = format
+	}
+	return New(opts)
+}
+
+func BenchmarkLogger(b *testing.B) {
+	for _, c := range benchCases {
+		c := c
+		b.Run(c.name, func(b *testing.B) {
+			log := c.setup(newBenchLogger(b, c.format))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				log(i)
+			}
+		})
+	}
+}
+
+func TestAllocationBudget(t *testing.T) {
+	if raceEnabled {
+		t.Skip("the race detector allocates")
+	}
+	for _, c := range benchCases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			log := c.setup(newBenchLogger(t, c.format))
+			i := 0
+			allocs := testing.AllocsPerRun(100, func() {
+				log(i)
+				i++
+			})
+			if allocs > c.budget {
+				t.Errorf("%v allocations per entry, budget is %v", allocs, c.budget)
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package logger
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// outputSink is one output of the logger. Every sink gets its own encoder,
+// so that color is only written where it can be displayed.
+type outputSink struct {
+	path    string
+	format  string
+	color   bool
+	enabler zapcore.LevelEnabler
+}
+
+// controls are the parts of a built logger that can be changed after New.
+type controls struct {
+	limiter *rateLimitState
+	// closers close the outputs opened by buildLogger.
+	closers   []func()
+	closeOnce sync.Once
+}
+
+// close closes the outputs of the logger, once.
+func (c *controls) close() {
+	c.closeOnce.Do(func() {
+		for _, closeOut := range c.closers {
+			closeOut()
+		}
+	})
+}
+
+// buildLogger does what zap.Config.Build does, except that the outputs are
+// the sinks of opts, each encoded on its own and joined with a tee.
+func buildLogger(
+	opts *Options,
+	cfg *zap.Config,
+	encoderConfig func(color bool) zapcore.EncoderConfig,
+	zapOpts ...zap.Option,
+) (*zap.Logger, *controls, error) {
+	ctrl := &controls{}
+	sinks := opts.outputSinks(cfg.Level)
+	cores := make([]zapcore.Core, 0, len(sinks))
+	replays := make([]zapcore.Core, 0, len(sinks))
+	for _, s := range sinks {
+		ws, closeOut, err := zap.Open(s.path)
+		if err != nil {
+			ctrl.close()
+			return nil, nil, err
+		}
+		ctrl.closers = append(ctrl.closers, closeOut)
+		if !s.color && s.format != jsonFormat {
+			ws = stripANSI(ws)
+		}
+		if opts.Metrics != nil {
+			ws = &countingWriteSyncer{WriteSyncer: ws, metrics: opts.Metrics.Sink(s.path)}
+		}
+		enc, err := newEncoder(s.format, encoderConfig(s.color && s.format != jsonFormat), s.color)
+		if err != nil {
+			ctrl.close()
+			return nil, nil, err
+		}
+		newCore := zapcore.NewCore
+		// zap.Open has rejected the invalid syslog paths
+		if syslogCfg, ok, _ := syslogConfigOf(s.path); ok {
+			newCore = func(enc zapcore.Encoder, ws zapcore.WriteSyncer, enab zapcore.LevelEnabler) zapcore.Core {
+				return newSyslogCore(syslogCfg, enc, ws, enab)
+			}
+		}
+		cores = append(cores, &sinkCore{Core: newCore(enc, ws, s.enabler)})
+		if opts.FlightRecorder.Size > 0 {
+			replayEnabler := s.replayEnabler(opts.FlightRecorder.level())
+			replays = append(replays, &sinkCore{Core: newCore(enc.Clone(), ws, replayEnabler)})
+		}
+	}
+	errSink, closeErr, err := zap.Open(cfg.ErrorOutputPaths...)
+	if err != nil {
+		ctrl.close()
+		return nil, nil, err
+	}
+	ctrl.closers = append(ctrl.closers, closeErr)
+
+	core := opts.wrapSinks(zapcore.NewTee(cores...))
+	if scfg := cfg.Sampling; scfg != nil {
+		var samplerOpts []zapcore.SamplerOption
+		if scfg.Hook != nil {
+			samplerOpts = append(samplerOpts, zapcore.SamplerHook(scfg.Hook))
+		}
+		core = zapcore.NewSamplerWithOptions(core, time.Second, scfg.Initial, scfg.Thereafter, samplerOpts...)
+	}
+	core = newDedupCore(core, opts.Dedup, errSink)
+	core, ctrl.limiter = newRateLimitCore(core, opts.RateLimit, errSink)
+	replay := opts.wrapSinks(zapcore.NewTee(replays...))
+	core = newRecorderCore(core, replay, opts.FlightRecorder, errSink)
+
+	if opts.ExitFunc != nil {
+		zapOpts = append(zapOpts, zap.WithFatalHook(exitHook(opts.ExitFunc)))
+	}
+	return zap.New(core, append(buildOptions(cfg, errSink), zapOpts...)...), ctrl, nil
+}
+
+// wrapSinks wraps the tee of the sinks with the GELF keys, the metrics, the
+// error encoding and the hooks. The replays of the flight recorder go through a tee of their
+// own, wrapped the same way.
+func (o *Options) wrapSinks(core zapcore.Core) zapcore.Core {
+	core = newGELFCore(core, o.Schema)
+	if o.Metrics != nil {
+		core = metricsCore(core, o.Metrics)
+	}
+	core = newErrorCore(core, o.ErrorEncoding)
+	return newHookCore(core, o.EntryHooks, o.Hooks)
+}
+
+// replayEnabler returns the levels of the sink for the entries of the flight
+// recorder: those from min when the sink follows the logger level.
+func (s outputSink) replayEnabler(min zapcore.Level) zapcore.LevelEnabler {
+	r, ok := s.enabler.(levelRange)
+	if !ok {
+		return min
+	}
+	if _, explicit := r.min.(zapcore.Level); !explicit {
+		r.min = min
+	}
+	return r
+}
+
+// sinkCore checks the level again when an entry is written. The cores
+// wrapping the tee of the sinks write to the tee directly, and a tee writes
+// to all of its cores regardless of their level.
+type sinkCore struct {
+	zapcore.Core
+}
+
+func (c *sinkCore) With(fields []zapcore.Field) zapcore.Core {
+	return &sinkCore{Core: c.Core.With(fields)}
+}
+
+func (c *sinkCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if !c.Enabled(ent.Level) {
+		return nil
+	}
+	return c.Core.Write(ent, fields)
+}
+
+// writeThrough writes an entry to core the way a zap.Logger does, checking
+// it first so that sampling and the levels of the sinks still apply. It is
+// used by the cores in front of the sampler. Write errors go to errOut.
+func writeThrough(core zapcore.Core, errOut zapcore.WriteSyncer, ent Entry, fields []Field) {
+	ce := core.Check(ent, nil)
+	if ce == nil {
+		return
+	}
+	ce.ErrorOutput = errOut
+	ce.Write(fields...)
+}
+
+func newEncoder(format string, cfg zapcore.EncoderConfig, color bool) (zapcore.Encoder, error) {
+	switch format {
+	case jsonFormat:
+		return zapcore.NewJSONEncoder(cfg), nil
+	case consoleFormat:
+		return zapcore.NewConsoleEncoder(cfg), nil
+	case prettyFormat:
+		return newPrettyEncoder(cfg, color), nil
+	default:
+		return nil, fmt.Errorf("not a valid log format: %q", format)
+	}
+}
+
+func buildOptions(cfg *zap.Config, errSink zapcore.WriteSyncer) []zap.Option {
+	opts := []zap.Option{zap.ErrorOutput(errSink)}
+	if cfg.Development {
+		opts = append(opts, zap.Development())
+	}
+	if !cfg.DisableCaller {
+		opts = append(opts, zap.AddCaller())
+	}
+	stackLevel := zap.ErrorLevel
+	if cfg.Development {
+		stackLevel = zap.WarnLevel
+	}
+	if !cfg.DisableStacktrace {
+		opts = append(opts, zap.AddStacktrace(stackLevel))
+	}
+
+	if len(cfg.InitialFields) > 0 {
+		keys := make([]string, 0, len(cfg.InitialFields))
+		for k := range cfg.InitialFields {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		fields := make([]zap.Field, 0, len(keys))
+		for _, k := range keys {
+			fields = append(fields, zap.Any(k, cfg.InitialFields[k]))
+		}
+		opts = append(opts, zap.Fields(fields...))
+	}
+	return opts
+}
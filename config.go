@@ -1,8 +1,6 @@
 package logger
 
 import (
-	"time"
-
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
@@ -31,9 +29,13 @@ type Config struct {
 	// 可以添加自定义的字段信息到 root logger 中。也就是每条日志都会携带这些字段信息，公共字段
 	InitialFields map[string]interface{} `json:"initialFields" yaml:"initialFields"`
 
+	// 编码器的字段名、时间格式、时长单位等设置，与 Options.Encoder 共用
+	Encoder EncoderOptions `json:"encoder" yaml:"encoder"`
+
 	EnableColor bool
 	ShortTime   bool
 
+	// Deprecated: CallerSkip 未被使用，没有代码从 Config 构建日志记录器，仅为兼容旧配置保留
 	CallerSkip int
 	zapConfig  *zap.Config
 }
@@ -142,38 +144,12 @@ func (c *Config) buildZapConfig() {
 }
 
 func (c *Config) newCustomEncoderConfig() zapcore.EncoderConfig {
-	encoderLevel := zapcore.LowercaseLevelEncoder
-	if c.EnableColor {
-		encoderLevel = zapcore.LowercaseColorLevelEncoder
-	}
-	encodeTime := zapcore.ISO8601TimeEncoder
-	if c.ShortTime {
-		encodeTime = func(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
-			type appendTimeEncoder interface {
-				AppendTimeLayout(time.Time, string)
-			}
-			layout := "2006-01-02 15:04:05"
-			if enc, ok := enc.(appendTimeEncoder); ok {
-				enc.AppendTimeLayout(t, layout)
-				return
-			}
-			enc.AppendString(t.Format(layout))
-		}
-	}
-
-	return zapcore.EncoderConfig{
-		TimeKey:        "time",
-		LevelKey:       "level",
-		NameKey:        "logger",
-		CallerKey:      "caller",
-		MessageKey:     "msg",
-		StacktraceKey:  "stacktrace",
-		LineEnding:     zapcore.DefaultLineEnding,
-		EncodeLevel:    encoderLevel,
-		EncodeTime:     encodeTime,
-		EncodeDuration: zapcore.SecondsDurationEncoder,
-		EncodeCaller:   zapcore.ShortCallerEncoder,
+	encoder := c.Encoder
+	if c.ShortTime && encoder.TimeLayout == "" {
+		encoder.TimeLayout = "2006-01-02 15:04:05"
 	}
+	preset, _ := SchemaDefault.preset()
+	return encoder.encoderConfig(preset, c.EnableColor)
 }
 
 func (c *Config) clone() *Config {
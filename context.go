@@ -1,6 +1,12 @@
 package logger
 
-import "context"
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"time"
+)
 
 type key int
 
@@ -25,3 +31,12 @@ func FromContext(ctx context.Context) Logger {
 	}
 	return WithName("Unknown-Context")
 }
+
+// NewRequestID returns a random id for a request arriving without one.
+func NewRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(b)
+}
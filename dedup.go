@@ -0,0 +1,225 @@
+package logger
+
+import (
+	"container/list"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const defaultDedupMaxEntries = 1000
+
+// DedupOptions collapses identical entries, same level, message and fields,
+// logged within a window into the first one and a summary with the number of
+// repetitions written when the window ends.
+type DedupOptions struct {
+	// Window during which repetitions are collapsed, zero disables dedup.
+	Window time.Duration `json:"window" yaml:"window" mapstructure:"window"`
+	// MaxEntries bounds the number of distinct entries remembered, the least
+	// recently logged one is forgotten first.
+	MaxEntries int `json:"max-entries" yaml:"max-entries" mapstructure:"max-entries"`
+}
+
+// dedupEntry is a distinct entry seen within the current window.
+type dedupEntry struct {
+	key    uint64
+	core   zapcore.Core
+	ent    Entry
+	fields []Field
+	first  time.Time
+	count  int
+}
+
+// dedupState is shared by a dedupCore and all cores derived with With.
+type dedupState struct {
+	mu      sync.Mutex
+	window  time.Duration
+	max     int
+	errOut  zapcore.WriteSyncer
+	entries map[uint64]*list.Element
+	lru     *list.List
+	timer   *time.Timer
+	hasher  zapcore.Encoder
+}
+
+// dedupCore suppresses the repetitions of an entry within the window. It
+// writes through the wrapped core with a check, so that it can sit in front
+// of the sampler without bypassing it.
+type dedupCore struct {
+	zapcore.Core
+	context uint64
+	state   *dedupState
+}
+
+func newDedupCore(core zapcore.Core, opts DedupOptions, errOut zapcore.WriteSyncer) zapcore.Core {
+	if opts.Window <= 0 {
+		return core
+	}
+	max := opts.MaxEntries
+	if max <= 0 {
+		max = defaultDedupMaxEntries
+	}
+	return &dedupCore{
+		Core: core,
+		state: &dedupState{
+			window:  opts.Window,
+			max:     max,
+			errOut:  errOut,
+			entries: make(map[uint64]*list.Element),
+			lru:     list.New(),
+			hasher:  zapcore.NewJSONEncoder(zapcore.EncoderConfig{}),
+		},
+	}
+}
+
+func (c *dedupCore) With(fields []Field) zapcore.Core {
+	return &dedupCore{
+		Core:    c.Core.With(fields),
+		context: c.state.hash(c.context, Entry{}, fields),
+		state:   c.state,
+	}
+}
+
+func (c *dedupCore) Check(ent Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *dedupCore) Write(ent Entry, fields []Field) error {
+	if ent.Level >= zapcore.DPanicLevel {
+		writeThrough(c.Core, c.state.errOut, ent, fields)
+		return nil
+	}
+	key := c.state.hash(c.context, ent, fields)
+	if c.state.record(key, c.Core, ent, fields) {
+		writeThrough(c.Core, c.state.errOut, ent, fields)
+	}
+	return nil
+}
+
+func (c *dedupCore) Sync() error {
+	c.state.flush(true)
+	return c.Core.Sync()
+}
+
+// hash identifies an entry by the hash of the context it was logged with,
+// its level, logger name, caller, message and encoded fields. The name is not
+// part of the context, WithName does not go through With.
+func (s *dedupState) hash(context uint64, ent Entry, fields []Field) uint64 {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%d|%d|%s|%s|%s|", context, ent.Level, ent.LoggerName, ent.Caller.String(), ent.Message)
+	if buf, err := s.hasher.EncodeEntry(Entry{}, fields); err == nil {
+		_, _ = h.Write(buf.Bytes())
+		buf.Free()
+	}
+	return h.Sum64()
+}
+
+// record reports whether the entry has to be written, and counts it as a
+// repetition otherwise.
+func (s *dedupState) record(key uint64, core zapcore.Core, ent Entry, fields []Field) bool {
+	var summaries []*dedupEntry
+	defer func() {
+		for _, e := range summaries {
+			s.writeSummary(e)
+		}
+	}()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if el, ok := s.entries[key]; ok {
+		e := el.Value.(*dedupEntry)
+		if ent.Time.Sub(e.first) < s.window {
+			e.count++
+			s.lru.MoveToFront(el)
+			s.schedule()
+			return false
+		}
+		if e.count > 0 {
+			summaries = append(summaries, s.detach(e))
+		}
+		e.first, e.count, e.ent = ent.Time, 0, ent
+		s.lru.MoveToFront(el)
+		return true
+	}
+
+	s.entries[key] = s.lru.PushFront(&dedupEntry{
+		key:    key,
+		core:   core,
+		ent:    ent,
+		fields: append([]Field(nil), fields...),
+		first:  ent.Time,
+	})
+	for s.lru.Len() > s.max {
+		e := s.lru.Remove(s.lru.Back()).(*dedupEntry)
+		delete(s.entries, e.key)
+		if e.count > 0 {
+			summaries = append(summaries, e)
+		}
+	}
+	return true
+}
+
+// detach returns a copy of e for its summary.
+func (s *dedupState) detach(e *dedupEntry) *dedupEntry {
+	cloned := *e
+	return &cloned
+}
+
+// schedule arms the timer writing the summaries of the current window.
+// The caller holds the lock.
+func (s *dedupState) schedule() {
+	if s.timer == nil {
+		s.timer = time.AfterFunc(s.window, func() { s.flush(false) })
+	}
+}
+
+// flush writes the summaries of the windows that ended, or of all windows
+// when all is set, and forgets the entries of the ended windows.
+func (s *dedupState) flush(all bool) {
+	var summaries []*dedupEntry
+	now := time.Now()
+
+	s.mu.Lock()
+	s.timer = nil
+	pending := false
+	for el := s.lru.Front(); el != nil; {
+		next := el.Next()
+		e := el.Value.(*dedupEntry)
+		ended := now.Sub(e.first) >= s.window
+		if e.count > 0 && (ended || all) {
+			summaries = append(summaries, s.detach(e))
+			e.count = 0
+		}
+		if ended {
+			s.lru.Remove(el)
+			delete(s.entries, e.key)
+		} else if e.count > 0 {
+			pending = true
+		}
+		el = next
+	}
+	if pending {
+		s.schedule()
+	}
+	s.mu.Unlock()
+
+	for _, e := range summaries {
+		s.writeSummary(e)
+	}
+}
+
+func (s *dedupState) writeSummary(e *dedupEntry) {
+	ent := e.ent
+	ent.Time = time.Now()
+	ent.Message = fmt.Sprintf("%s (repeated %d times in %s)", e.ent.Message, e.count, s.window)
+	fields := append(e.fields[:len(e.fields):len(e.fields)], zap.Int("repeated", e.count))
+	writeThrough(e.core, s.errOut, ent, fields)
+}
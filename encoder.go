@@ -1,11 +1,180 @@
 package logger
 
 import (
+	"fmt"
+	"strings"
 	"time"
 
 	"go.uber.org/zap/zapcore"
 )
 
+const (
+	durationUnitMillis  = "ms"
+	durationUnitSeconds = "s"
+	durationUnitNanos   = "ns"
+	durationUnitString  = "string"
+
+	levelCaseCapital = "capital"
+	levelCaseLower   = "lower"
+
+	callerShort = "short"
+	callerFull  = "full"
+)
+
+// EncoderOptions customizes the keys and value encoders of the log output.
+// Empty fields keep the value of the selected Schema, so the zero value
+// reproduces the default layout. It is shared by Options and Config.
+type EncoderOptions struct {
+	MessageKey    string `json:"message-key" yaml:"message-key" mapstructure:"message-key"`
+	LevelKey      string `json:"level-key" yaml:"level-key" mapstructure:"level-key"`
+	TimeKey       string `json:"time-key" yaml:"time-key" mapstructure:"time-key"`
+	NameKey       string `json:"name-key" yaml:"name-key" mapstructure:"name-key"`
+	CallerKey     string `json:"caller-key" yaml:"caller-key" mapstructure:"caller-key"`
+	StacktraceKey string `json:"stacktrace-key" yaml:"stacktrace-key" mapstructure:"stacktrace-key"`
+	// TimeLayout is a Go time layout or one of epoch, epoch-millis,
+	// epoch-nanos, iso8601, rfc3339 and rfc3339nano.
+	TimeLayout string `json:"time-layout" yaml:"time-layout" mapstructure:"time-layout"`
+	// UTC converts timestamps to UTC before they are encoded.
+	UTC bool `json:"time-utc" yaml:"time-utc" mapstructure:"time-utc"`
+	// DurationUnit is one of ms, s, ns or string.
+	DurationUnit string `json:"duration-unit" yaml:"duration-unit" mapstructure:"duration-unit"`
+	// LevelCase is capital or lower.
+	LevelCase string `json:"level-case" yaml:"level-case" mapstructure:"level-case"`
+	// Caller is short or full.
+	Caller string `json:"caller" yaml:"caller" mapstructure:"caller"`
+}
+
+// Validate checks the enumerated settings of the encoder.
+func (e *EncoderOptions) Validate() []error {
+	var errs []error
+	if _, err := e.durationEncoder(); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := e.levelEncoder(schemaPreset{}, false); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := e.callerEncoder(); err != nil {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// encoderConfig builds the zap encoder config from a schema preset with the
+// non-empty settings applied on top. Invalid settings fall back to the preset.
+func (e *EncoderOptions) encoderConfig(preset schemaPreset, color bool) zapcore.EncoderConfig {
+	cfg := zapcore.EncoderConfig{
+		MessageKey:     firstNonEmpty(e.MessageKey, preset.messageKey),
+		LevelKey:       firstNonEmpty(e.LevelKey, preset.levelKey),
+		TimeKey:        firstNonEmpty(e.TimeKey, preset.timeKey),
+		NameKey:        firstNonEmpty(e.NameKey, preset.nameKey),
+		CallerKey:      firstNonEmpty(e.CallerKey, preset.callerKey),
+		StacktraceKey:  firstNonEmpty(e.StacktraceKey, preset.stacktraceKey),
+		LineEnding:     zapcore.DefaultLineEnding,
+		EncodeLevel:    preset.encodeLevel,
+		EncodeTime:     e.timeEncoder(preset.encodeTime),
+		EncodeDuration: milliSecondsDurationEncoder,
+		EncodeCaller:   zapcore.ShortCallerEncoder,
+	}
+	if enc, err := e.levelEncoder(preset, color); err == nil {
+		cfg.EncodeLevel = enc
+	}
+	if enc, err := e.durationEncoder(); err == nil {
+		cfg.EncodeDuration = enc
+	}
+	if enc, err := e.callerEncoder(); err == nil {
+		cfg.EncodeCaller = enc
+	}
+	if preset.originCaller && e.CallerKey == "" {
+		cfg.EncodeCaller = originCallerEncoder(strings.EqualFold(e.Caller, callerFull), cfg.EncodeCaller)
+	}
+	return cfg
+}
+
+func (e *EncoderOptions) timeEncoder(dft zapcore.TimeEncoder) zapcore.TimeEncoder {
+	var enc zapcore.TimeEncoder
+	switch strings.ToLower(e.TimeLayout) {
+	case "":
+		enc = dft
+	case "epoch":
+		enc = zapcore.EpochTimeEncoder
+	case "epoch-millis":
+		enc = zapcore.EpochMillisTimeEncoder
+	case "epoch-nanos":
+		enc = zapcore.EpochNanosTimeEncoder
+	case "iso8601":
+		enc = zapcore.ISO8601TimeEncoder
+	case "rfc3339":
+		enc = zapcore.RFC3339TimeEncoder
+	case "rfc3339nano":
+		enc = zapcore.RFC3339NanoTimeEncoder
+	default:
+		enc = zapcore.TimeEncoderOfLayout(e.TimeLayout)
+	}
+	if !e.UTC {
+		return enc
+	}
+	return func(t time.Time, pae zapcore.PrimitiveArrayEncoder) {
+		enc(t.UTC(), pae)
+	}
+}
+
+func (e *EncoderOptions) durationEncoder() (zapcore.DurationEncoder, error) {
+	switch strings.ToLower(e.DurationUnit) {
+	case "", durationUnitMillis:
+		return milliSecondsDurationEncoder, nil
+	case durationUnitSeconds:
+		return zapcore.SecondsDurationEncoder, nil
+	case durationUnitNanos:
+		return zapcore.NanosDurationEncoder, nil
+	case durationUnitString:
+		return zapcore.StringDurationEncoder, nil
+	default:
+		return nil, fmt.Errorf("not a valid duration unit: %q", e.DurationUnit)
+	}
+}
+
+func (e *EncoderOptions) levelEncoder(preset schemaPreset, color bool) (zapcore.LevelEncoder, error) {
+	switch strings.ToLower(e.LevelCase) {
+	case "":
+		if color && preset.colorLevel != nil {
+			return preset.colorLevel, nil
+		}
+		return preset.encodeLevel, nil
+	case levelCaseCapital:
+		if color {
+			return zapcore.CapitalColorLevelEncoder, nil
+		}
+		return zapcore.CapitalLevelEncoder, nil
+	case levelCaseLower:
+		if color {
+			return zapcore.LowercaseColorLevelEncoder, nil
+		}
+		return zapcore.LowercaseLevelEncoder, nil
+	default:
+		return nil, fmt.Errorf("not a valid level case: %q", e.LevelCase)
+	}
+}
+
+func (e *EncoderOptions) callerEncoder() (zapcore.CallerEncoder, error) {
+	switch strings.ToLower(e.Caller) {
+	case "", callerShort:
+		return zapcore.ShortCallerEncoder, nil
+	case callerFull:
+		return zapcore.FullCallerEncoder, nil
+	default:
+		return nil, fmt.Errorf("not a valid caller encoder: %q", e.Caller)
+	}
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
 func timeEncoder(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
 	enc.AppendString(t.Format("2006-01-02 15:04:05.000"))
 }
@@ -0,0 +1,162 @@
+package logger
+
+import (
+	"fmt"
+	"reflect"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// errorEncodingDefault keeps zap's encoding, the message under the field
+	// key and the verbose form under the key with a Verbose suffix.
+	errorEncodingDefault = "default"
+	// errorEncodingRich encodes errors as objects with the message, the type,
+	// the chain of wrapped errors and the stack trace.
+	errorEncodingRich = "rich"
+)
+
+// maxErrorChain bounds the walk through wrapped errors.
+const maxErrorChain = 32
+
+// richError encodes an error as an object with its message, its type, the
+// messages of the errors it wraps and a stack trace when one of them carries
+// frames, as errors of github.com/pkg/errors do.
+type richError struct {
+	err error
+}
+
+func (e richError) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("message", e.err.Error())
+	enc.AddString("type", errorType(e.err))
+	if chain := errorChain(e.err); len(chain) > 0 {
+		if err := enc.AddArray("chain", zapcore.ArrayMarshalerFunc(func(arr zapcore.ArrayEncoder) error {
+			for _, err := range chain {
+				arr.AppendString(err.Error())
+			}
+			return nil
+		})); err != nil {
+			return err
+		}
+	}
+	if stack := errorStack(e.err); stack != "" {
+		enc.AddString("stack", stack)
+	}
+	return nil
+}
+
+func errorType(err error) string {
+	return reflect.TypeOf(err).String()
+}
+
+// errorChain returns the errors wrapped by err, depth first, following
+// Unwrap() error, Unwrap() []error as produced by errors.Join and the Cause()
+// error method of github.com/pkg/errors.
+func errorChain(err error) []error {
+	var chain []error
+	var walk func(err error)
+	walk = func(err error) {
+		var next []error
+		switch e := err.(type) {
+		case interface{ Unwrap() []error }:
+			next = e.Unwrap()
+		case interface{ Unwrap() error }:
+			next = []error{e.Unwrap()}
+		case interface{ Cause() error }:
+			next = []error{e.Cause()}
+		}
+		for _, n := range next {
+			if isNilError(n) || len(chain) >= maxErrorChain {
+				continue
+			}
+			chain = append(chain, n)
+			walk(n)
+		}
+	}
+	walk(err)
+	return chain
+}
+
+// errorStack returns the stack trace of the innermost error carrying one. The
+// frames are read from a StackTrace method, whatever its result type, and
+// formatted with %+v as github.com/pkg/errors documents.
+func errorStack(err error) string {
+	var stack string
+	for _, e := range append([]error{err}, errorChain(err)...) {
+		method := reflect.ValueOf(e).MethodByName("StackTrace")
+		if !method.IsValid() || method.Type().NumIn() != 0 || method.Type().NumOut() != 1 {
+			continue
+		}
+		if s := fmt.Sprintf("%+v", method.Call(nil)[0].Interface()); s != "" {
+			stack = s
+		}
+	}
+	return stack
+}
+
+// errorCore replaces the error fields by rich error objects before they are
+// encoded.
+type errorCore struct {
+	zapcore.Core
+}
+
+func newErrorCore(core zapcore.Core, encoding string) zapcore.Core {
+	if encoding != errorEncodingRich {
+		return core
+	}
+	return &errorCore{Core: core}
+}
+
+func (c *errorCore) With(fields []Field) zapcore.Core {
+	return &errorCore{Core: c.Core.With(richErrorFields(fields))}
+}
+
+func (c *errorCore) Check(ent Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *errorCore) Write(ent Entry, fields []Field) error {
+	return c.Core.Write(ent, richErrorFields(fields))
+}
+
+// richErrorFields returns fields with every error field replaced, the slice
+// is only copied when it holds an error.
+func richErrorFields(fields []Field) []Field {
+	for i := range fields {
+		if fields[i].Type != zapcore.ErrorType {
+			continue
+		}
+		replaced := make([]Field, len(fields))
+		copy(replaced, fields)
+		for j := i; j < len(replaced); j++ {
+			replaced[j] = richErrorField(replaced[j])
+		}
+		return replaced
+	}
+	return fields
+}
+
+func richErrorField(f Field) Field {
+	if f.Type != zapcore.ErrorType {
+		return f
+	}
+	err, ok := f.Interface.(error)
+	if !ok || isNilError(err) {
+		return f
+	}
+	return zap.Object(f.Key, richError{err: err})
+}
+
+// isNilError reports whether err is nil or holds a nil pointer, whose methods
+// would panic. zap encodes both as "<nil>".
+func isNilError(err error) bool {
+	if err == nil {
+		return true
+	}
+	v := reflect.ValueOf(err)
+	return v.Kind() == reflect.Ptr && v.IsNil()
+}
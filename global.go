@@ -1,3 +1,42 @@
 package logger
 
+import (
+	"github.com/costa92/logger/klog"
+	"go.uber.org/zap"
+)
+
 var defaultConfig = NewOptions()
+
+// InstallGlobals makes l the output of the process-wide loggers its options
+// ask for: the standard library log package with RedirectStdLog, klog with
+// RedirectKlog and zap.L and zap.S with ReplaceZapGlobals. New never does
+// this on its own. The returned function undoes the installation.
+func (l *logger) InstallGlobals() (undo func()) {
+	if l.options == nil {
+		return func() {}
+	}
+	// the loggers of New skip the frame of their own methods, callers of the
+	// globals log through zap directly
+	base := l.zapLogger.WithOptions(zap.AddCallerSkip(-1))
+	var undos []func()
+	if l.options.RedirectStdLog {
+		undos = append(undos, zap.RedirectStdLog(base))
+	}
+	if l.options.RedirectKlog {
+		undos = append(undos, klog.Install(base))
+	}
+	if l.options.ReplaceZapGlobals {
+		undos = append(undos, zap.ReplaceGlobals(base))
+	}
+	return func() {
+		for i := len(undos) - 1; i >= 0; i-- {
+			undos[i]()
+		}
+	}
+}
+
+// InstallGlobals installs the global logger as InstallGlobals of a logger
+// does.
+func InstallGlobals() (undo func()) {
+	return std.InstallGlobals()
+}
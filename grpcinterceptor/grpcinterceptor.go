@@ -0,0 +1,320 @@
+// Package grpcinterceptor provides gRPC server and client interceptors that
+// propagate request ids through metadata, attach a request-scoped logger to
+// the context and log every call.
+package grpcinterceptor
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/costa92/logger"
+)
+
+// DefaultRequestIDKey is the metadata key of the request id. gRPC metadata
+// keys are lower case, so logger.KeyRequestID cannot be used as is.
+const DefaultRequestIDKey = "x-request-id"
+
+// Options configures the interceptors.
+type Options struct {
+	// Logger writes the call log and is the parent of the request-scoped
+	// logger. When nil the global logger at the time of the call is used.
+	Logger logger.Logger
+	// RequestIDKey is the metadata key carrying the request id.
+	RequestIDKey string
+	// SkipMethods are full method names without call log, e.g. health checks.
+	SkipMethods []string
+	// LogPayloads adds the request and response messages of unary calls.
+	LogPayloads bool
+	// Level returns the level of the call log for a status code.
+	Level func(code codes.Code) logger.Level
+}
+
+// NewOptions returns the default options.
+func NewOptions() *Options {
+	return &Options{
+		RequestIDKey: DefaultRequestIDKey,
+		Level:        LevelByCode,
+	}
+}
+
+// LevelByCode logs successful calls at info level, errors caused by the
+// caller at warn level and the remaining errors at error level.
+func LevelByCode(code codes.Code) logger.Level {
+	// nolint: exhaustive
+	switch code {
+	case codes.OK:
+		return logger.InfoLevel
+	case codes.Canceled, codes.InvalidArgument, codes.NotFound, codes.AlreadyExists,
+		codes.PermissionDenied, codes.Unauthenticated, codes.ResourceExhausted,
+		codes.FailedPrecondition, codes.OutOfRange:
+		return logger.WarnLevel
+	default:
+		return logger.ErrorLevel
+	}
+}
+
+type interceptor struct {
+	opts  *Options
+	key   string
+	level func(code codes.Code) logger.Level
+	skip  map[string]struct{}
+}
+
+func newInterceptor(opts *Options) *interceptor {
+	if opts == nil {
+		opts = NewOptions()
+	}
+	i := &interceptor{
+		opts:  opts,
+		key:   opts.RequestIDKey,
+		level: opts.Level,
+		skip:  make(map[string]struct{}, len(opts.SkipMethods)),
+	}
+	if i.key == "" {
+		i.key = DefaultRequestIDKey
+	}
+	if i.level == nil {
+		i.level = LevelByCode
+	}
+	for _, method := range opts.SkipMethods {
+		i.skip[method] = struct{}{}
+	}
+	return i
+}
+
+func (i *interceptor) logger() logger.Logger {
+	if i.opts.Logger != nil {
+		return i.opts.Logger
+	}
+	return logger.With()
+}
+
+// serverContext reads or creates the request id of an incoming call and
+// returns the context carrying it together with the request-scoped logger.
+func (i *interceptor) serverContext(ctx context.Context) (context.Context, logger.Logger, string) {
+	var requestID string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(i.key); len(values) > 0 {
+			requestID = values[0]
+		}
+	}
+	if requestID == "" {
+		requestID = logger.NewRequestID()
+	}
+	// nolint: staticcheck
+	ctx = context.WithValue(ctx, logger.KeyRequestID, requestID)
+	log := i.logger().Ctx(ctx).With(logger.KeyRequestID, requestID)
+	return log.WithContext(ctx), log, requestID
+}
+
+// clientContext forwards the request id of ctx, or a new one, to the server.
+func (i *interceptor) clientContext(ctx context.Context) (context.Context, logger.Logger) {
+	requestID, _ := ctx.Value(logger.KeyRequestID).(string)
+	if requestID == "" {
+		requestID = logger.NewRequestID()
+	}
+	ctx = metadata.AppendToOutgoingContext(ctx, i.key, requestID)
+	return ctx, i.logger().Ctx(ctx).With(logger.KeyRequestID, requestID)
+}
+
+func (i *interceptor) log(log logger.Logger, msg, method string, start time.Time, err error, extra ...interface{}) {
+	if _, ok := i.skip[method]; ok {
+		return
+	}
+	code := status.Code(err)
+	keysAndValues := []interface{}{
+		"grpc.service", path.Dir(method)[1:],
+		"grpc.method", path.Base(method),
+		"grpc.code", code.String(),
+		"duration", time.Since(start),
+	}
+	keysAndValues = append(keysAndValues, extra...)
+	if err != nil {
+		keysAndValues = append(keysAndValues, "error", err)
+	}
+	log.Logw(i.level(code), msg, keysAndValues...)
+}
+
+func (i *interceptor) payload(key string, msg interface{}) []interface{} {
+	if !i.opts.LogPayloads || msg == nil {
+		return nil
+	}
+	if m, ok := msg.(proto.Message); ok {
+		if data, err := protojson.Marshal(m); err == nil {
+			return []interface{}{key, string(data)}
+		}
+	}
+	return []interface{}{key, msg}
+}
+
+func peerAddr(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		return p.Addr.String()
+	}
+	return ""
+}
+
+// UnaryServerInterceptor logs unary calls and injects the request-scoped
+// logger, which handlers get with logger.FromContext.
+func UnaryServerInterceptor(opts *Options) grpc.UnaryServerInterceptor {
+	i := newInterceptor(opts)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		start := time.Now()
+		ctx, log, requestID := i.serverContext(ctx)
+		_ = grpc.SetHeader(ctx, metadata.Pairs(i.key, requestID))
+
+		resp, err := handler(ctx, req)
+
+		extra := []interface{}{"peer", peerAddr(ctx)}
+		extra = append(extra, i.payload("grpc.request", req)...)
+		if err == nil {
+			extra = append(extra, i.payload("grpc.response", resp)...)
+		}
+		i.log(log, "grpc server call", info.FullMethod, start, err, extra...)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor logs streaming calls and injects the
+// request-scoped logger into the context of the stream.
+func StreamServerInterceptor(opts *Options) grpc.StreamServerInterceptor {
+	i := newInterceptor(opts)
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		start := time.Now()
+		ctx, log, requestID := i.serverContext(ss.Context())
+		_ = ss.SetHeader(metadata.Pairs(i.key, requestID))
+
+		err := handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+
+		i.log(log, "grpc server stream", info.FullMethod, start, err, "peer", peerAddr(ctx))
+		return err
+	}
+}
+
+// UnaryClientInterceptor forwards the request id and logs unary calls.
+func UnaryClientInterceptor(opts *Options) grpc.UnaryClientInterceptor {
+	i := newInterceptor(opts)
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker, callOpts ...grpc.CallOption,
+	) error {
+		start := time.Now()
+		ctx, log := i.clientContext(ctx)
+
+		err := invoker(ctx, method, req, reply, cc, callOpts...)
+
+		extra := []interface{}{"grpc.target", cc.Target()}
+		extra = append(extra, i.payload("grpc.request", req)...)
+		if err == nil {
+			extra = append(extra, i.payload("grpc.response", reply)...)
+		}
+		i.log(log, "grpc client call", method, start, err, extra...)
+		return err
+	}
+}
+
+// StreamClientInterceptor forwards the request id and logs streaming calls
+// once the stream fails to open, has received its response or has been read
+// until its end, fails to send, or once the context of the call is done.
+func StreamClientInterceptor(opts *Options) grpc.StreamClientInterceptor {
+	i := newInterceptor(opts)
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string,
+		streamer grpc.Streamer, callOpts ...grpc.CallOption,
+	) (grpc.ClientStream, error) {
+		start := time.Now()
+		ctx, log := i.clientContext(ctx)
+
+		cs, err := streamer(ctx, desc, cc, method, callOpts...)
+		if err != nil {
+			i.log(log, "grpc client stream", method, start, err, "grpc.target", cc.Target())
+			return nil, err
+		}
+		s := &clientStream{
+			ClientStream:  cs,
+			serverStreams: desc.ServerStreams,
+			done:          make(chan struct{}),
+			finish: func(err error) {
+				i.log(log, "grpc client stream", method, start, err, "grpc.target", cc.Target())
+			},
+		}
+		// a caller that stops reading ends the stream by canceling its context
+		go func() {
+			select {
+			case <-ctx.Done():
+				s.end(status.FromContextError(ctx.Err()).Err())
+			case <-s.done:
+			}
+		}()
+		return s, nil
+	}
+}
+
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+type clientStream struct {
+	grpc.ClientStream
+	// serverStreams is false when the server sends a single response, which
+	// ends the call.
+	serverStreams bool
+	once          sync.Once
+	done          chan struct{}
+	finish        func(err error)
+}
+
+// end logs the call with err once, io.EOF being the end of a successful call.
+func (s *clientStream) end(err error) {
+	s.once.Do(func() {
+		if errors.Is(err, io.EOF) {
+			err = nil
+		}
+		s.finish(err)
+		close(s.done)
+	})
+}
+
+func (s *clientStream) Header() (metadata.MD, error) {
+	md, err := s.ClientStream.Header()
+	if err != nil {
+		s.end(err)
+	}
+	return md, err
+}
+
+func (s *clientStream) SendMsg(m interface{}) error {
+	err := s.ClientStream.SendMsg(m)
+	// io.EOF tells that the stream ended, its status is returned by RecvMsg
+	if err != nil && !errors.Is(err, io.EOF) {
+		s.end(err)
+	}
+	return err
+}
+
+func (s *clientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err != nil || !s.serverStreams {
+		s.end(err)
+	}
+	return err
+}
@@ -0,0 +1,202 @@
+package grpcinterceptor
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/costa92/logger"
+	"github.com/costa92/logger/logtest"
+)
+
+func TestInterceptors(t *testing.T) {
+	l, output := logtest.New(t, nil)
+
+	opts := NewOptions()
+	opts.Logger = l
+	opts.LogPayloads = true
+
+	probe := func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		logger.FromContext(ctx).Infow("inside handler")
+		return handler(ctx, req)
+	}
+
+	srv, conn := serve(t, opts, probe, func(s *grpc.Server) {
+		healthpb.RegisterHealthServer(s, health.NewServer())
+	})
+	defer srv.Stop()
+	defer conn.Close()
+
+	// nolint: staticcheck
+	ctx := context.WithValue(context.Background(), logger.KeyRequestID, "req-1")
+	client := healthpb.NewHealthClient(conn)
+	if _, err := client.Check(ctx, &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: "unknown"}); err == nil {
+		t.Fatal("expected NotFound for an unknown service")
+	}
+
+	out := output()
+	for _, want := range []string{
+		`"message":"inside handler","X-Request-ID":"req-1"`,
+		`"message":"grpc server call","X-Request-ID":"req-1","grpc.service":"grpc.health.v1.Health","grpc.method":"Check","grpc.code":"OK"`,
+		`"message":"grpc client call","X-Request-ID":"req-1"`,
+		`"grpc.response":"{\"status\":\"SERVING\"}"`,
+		`"level":"WARN"`,
+		`"grpc.code":"NotFound"`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %s in\n%s", want, out)
+		}
+	}
+}
+
+// streamDesc is a service with a client streaming and a bidirectional method,
+// echoing health check requests as responses.
+var streamDesc = grpc.ServiceDesc{
+	ServiceName: "test.Echo",
+	HandlerType: (*interface{})(nil),
+	Streams: []grpc.StreamDesc{
+		{StreamName: "Collect", ClientStreams: true, Handler: collect},
+		{StreamName: "Chat", ClientStreams: true, ServerStreams: true, Handler: chat},
+	},
+}
+
+// collect answers with the last request once the client closes its side.
+func collect(_ interface{}, ss grpc.ServerStream) error {
+	var last healthpb.HealthCheckRequest
+	for {
+		req := new(healthpb.HealthCheckRequest)
+		if err := ss.RecvMsg(req); errors.Is(err, io.EOF) {
+			return ss.SendMsg(&healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_ServingStatus(len(last.Service))})
+		} else if err != nil {
+			return err
+		}
+		last.Service = req.Service
+	}
+}
+
+// chat answers every request until the client ends the stream.
+func chat(_ interface{}, ss grpc.ServerStream) error {
+	for {
+		if err := ss.RecvMsg(new(healthpb.HealthCheckRequest)); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if err := ss.SendMsg(&healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}); err != nil {
+			return err
+		}
+	}
+}
+
+func TestStreamInterceptors(t *testing.T) {
+	l, output := logtest.New(t, nil)
+
+	opts := NewOptions()
+	opts.Logger = l
+	srv, conn := serve(t, opts, nil, func(s *grpc.Server) {
+		s.RegisterService(&streamDesc, nil)
+	})
+	defer conn.Close()
+
+	// nolint: staticcheck
+	ctx := context.WithValue(context.Background(), logger.KeyRequestID, "req-1")
+	collectStream, err := conn.NewStream(ctx, &streamDesc.Streams[0], "/test.Echo/Collect")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, service := range []string{"a", "bc"} {
+		if err := collectStream.SendMsg(&healthpb.HealthCheckRequest{Service: service}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := collectStream.CloseSend(); err != nil {
+		t.Fatal(err)
+	}
+	if err := collectStream.RecvMsg(new(healthpb.HealthCheckResponse)); err != nil {
+		t.Fatal(err)
+	}
+
+	// the client stops reading the bidirectional stream and cancels it
+	chatCtx, cancel := context.WithCancel(ctx)
+	chatStream, err := conn.NewStream(chatCtx, &streamDesc.Streams[1], "/test.Echo/Chat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := chatStream.SendMsg(&healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := chatStream.RecvMsg(new(healthpb.HealthCheckResponse)); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	<-chatStream.(*clientStream).done
+	srv.GracefulStop()
+
+	out := output()
+	for _, want := range []string{
+		`"message":"grpc server stream","X-Request-ID":"req-1","grpc.service":"test.Echo","grpc.method":"Collect","grpc.code":"OK"`,
+		`"message":"grpc client stream","X-Request-ID":"req-1","grpc.service":"test.Echo","grpc.method":"Collect","grpc.code":"OK"`,
+		`"message":"grpc server stream","X-Request-ID":"req-1","grpc.service":"test.Echo","grpc.method":"Chat","grpc.code":"` + codes.Canceled.String() + `"`,
+		`"message":"grpc client stream","X-Request-ID":"req-1","grpc.service":"test.Echo","grpc.method":"Chat","grpc.code":"` + codes.Canceled.String() + `"`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %s in\n%s", want, out)
+		}
+	}
+	if n := strings.Count(out, `"grpc client stream"`); n != 2 {
+		t.Errorf("expected one log per client stream, got %d", n)
+	}
+	if code := status.Code(chatStream.RecvMsg(new(healthpb.HealthCheckResponse))); code != codes.Canceled {
+		t.Errorf("unexpected code %s of the canceled stream", code)
+	}
+}
+
+// serve starts a server with the interceptors of opts and the services of
+// register, listening on an in-memory connection, and returns a client
+// connection to it.
+func serve(t *testing.T, opts *Options, unary grpc.UnaryServerInterceptor, register func(*grpc.Server),
+) (*grpc.Server, *grpc.ClientConn) {
+	t.Helper()
+	chain := []grpc.UnaryServerInterceptor{UnaryServerInterceptor(opts)}
+	if unary != nil {
+		chain = append(chain, unary)
+	}
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(chain...),
+		grpc.StreamInterceptor(StreamServerInterceptor(opts)),
+	)
+	register(srv)
+	go func() { _ = srv.Serve(lis) }()
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(UnaryClientInterceptor(opts)),
+		grpc.WithStreamInterceptor(StreamClientInterceptor(opts)),
+	)
+	if err != nil {
+		srv.Stop()
+		t.Fatal(err)
+	}
+	return srv, conn
+}
@@ -0,0 +1,59 @@
+package logger
+
+import (
+	"go.uber.org/multierr"
+	"go.uber.org/zap/zapcore"
+)
+
+// EntryHook runs before an entry is written and sees the fields added with
+// With as well as the fields of the call. It may change the entry, returns
+// the fields to write and vetoes the entry by returning false.
+type EntryHook func(ent *Entry, fields []Field) ([]Field, bool)
+
+// hookCore runs the entry hooks before and the hooks after an entry is
+// written. It keeps the fields added with With to itself, so that hooks can
+// see and replace them, and passes them to the wrapped core on every write.
+type hookCore struct {
+	zapcore.Core
+	fields []Field
+	before []EntryHook
+	after  []func(Entry, []Field) error
+}
+
+func newHookCore(core zapcore.Core, before []EntryHook, after []func(Entry, []Field) error) zapcore.Core {
+	if len(before) == 0 && len(after) == 0 {
+		return core
+	}
+	return &hookCore{Core: core, before: before, after: after}
+}
+
+func (c *hookCore) With(fields []Field) zapcore.Core {
+	cloned := *c
+	cloned.fields = append(c.fields[:len(c.fields):len(c.fields)], fields...)
+	return &cloned
+}
+
+func (c *hookCore) Check(ent Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *hookCore) Write(ent Entry, fields []Field) error {
+	all := make([]Field, 0, len(c.fields)+len(fields))
+	all = append(all, c.fields...)
+	all = append(all, fields...)
+
+	for _, hook := range c.before {
+		var ok bool
+		if all, ok = hook(&ent, all); !ok {
+			return nil
+		}
+	}
+	err := c.Core.Write(ent, all)
+	for _, hook := range c.after {
+		err = multierr.Append(err, hook(ent, all))
+	}
+	return err
+}
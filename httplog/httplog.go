@@ -0,0 +1,181 @@
+// Package httplog provides a net/http middleware that propagates request ids,
+// stores a request-scoped logger in the request context and writes an access
+// log for every request.
+package httplog
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/costa92/logger"
+)
+
+// Options configures the middleware.
+type Options struct {
+	// Logger writes the access log and is the parent of the request-scoped
+	// logger. When nil the global logger at the time of the request is used.
+	Logger logger.Logger
+	// RequestIDHeader is the header read and written with the request id.
+	RequestIDHeader string
+	// SkipPaths are request paths without access log, e.g. health checks.
+	// The request id and the request-scoped logger are still set up.
+	SkipPaths []string
+	// Headers are the request headers copied into the access log.
+	Headers []string
+	// Level returns the level of the access log for a response status.
+	Level func(status int) logger.Level
+}
+
+// NewOptions returns the default options, which log at error level for
+// server errors, at warn level for client errors and at info level otherwise.
+func NewOptions() *Options {
+	return &Options{
+		RequestIDHeader: logger.KeyRequestID,
+		Level:           LevelByStatus,
+	}
+}
+
+// LevelByStatus chooses the access log level from the status class.
+func LevelByStatus(status int) logger.Level {
+	switch {
+	case status >= http.StatusInternalServerError:
+		return logger.ErrorLevel
+	case status >= http.StatusBadRequest:
+		return logger.WarnLevel
+	default:
+		return logger.InfoLevel
+	}
+}
+
+// Middleware returns the middleware in the form used by most routers.
+func Middleware(opts *Options) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return Handler(next, opts)
+	}
+}
+
+// Handler wraps next with request id propagation and access logging.
+func Handler(next http.Handler, opts *Options) http.Handler {
+	if opts == nil {
+		opts = NewOptions()
+	}
+	h := &handler{
+		next:    next,
+		opts:    opts,
+		header:  opts.RequestIDHeader,
+		level:   opts.Level,
+		skip:    make(map[string]struct{}, len(opts.SkipPaths)),
+		headers: opts.Headers,
+	}
+	if h.header == "" {
+		h.header = logger.KeyRequestID
+	}
+	if h.level == nil {
+		h.level = LevelByStatus
+	}
+	for _, path := range opts.SkipPaths {
+		h.skip[path] = struct{}{}
+	}
+	return h
+}
+
+type handler struct {
+	next    http.Handler
+	opts    *Options
+	header  string
+	level   func(status int) logger.Level
+	skip    map[string]struct{}
+	headers []string
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+
+	requestID := r.Header.Get(h.header)
+	if requestID == "" {
+		requestID = logger.NewRequestID()
+	}
+	w.Header().Set(h.header, requestID)
+
+	// nolint: staticcheck
+	ctx := context.WithValue(r.Context(), logger.KeyRequestID, requestID)
+	log := h.logger().Ctx(ctx).With(logger.KeyRequestID, requestID)
+	ctx = log.WithContext(ctx)
+
+	rw := &responseWriter{ResponseWriter: w, status: http.StatusOK}
+	h.next.ServeHTTP(rw, r.WithContext(ctx))
+
+	if _, ok := h.skip[r.URL.Path]; ok {
+		return
+	}
+	keysAndValues := []interface{}{
+		"method", r.Method,
+		"path", r.URL.Path,
+		"status", rw.status,
+		"latency", time.Since(start),
+		"bytes", rw.bytes,
+		"remote_addr", r.RemoteAddr,
+	}
+	if r.URL.RawQuery != "" {
+		keysAndValues = append(keysAndValues, "query", r.URL.RawQuery)
+	}
+	for _, name := range h.headers {
+		if value := r.Header.Get(name); value != "" {
+			keysAndValues = append(keysAndValues, "header."+strings.ToLower(name), value)
+		}
+	}
+	log.Logw(h.level(rw.status), "http request", keysAndValues...)
+}
+
+func (h *handler) logger() logger.Logger {
+	if h.opts.Logger != nil {
+		return h.opts.Logger
+	}
+	return logger.With()
+}
+
+// responseWriter records the status and the size of the response.
+type responseWriter struct {
+	http.ResponseWriter
+	status      int
+	bytes       int
+	wroteHeader bool
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(p []byte) (int, error) {
+	w.wroteHeader = true
+	n, err := w.ResponseWriter.Write(p)
+	w.bytes += n
+	return n, err
+}
+
+func (w *responseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, fmt.Errorf("httplog: %T does not implement http.Hijacker", w.ResponseWriter)
+}
+
+// Unwrap lets http.ResponseController reach the wrapped writer.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
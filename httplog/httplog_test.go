@@ -0,0 +1,60 @@
+package httplog
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/costa92/logger"
+	"github.com/costa92/logger/logtest"
+)
+
+func TestHandler(t *testing.T) {
+	l, output := logtest.New(t, nil)
+	opts := NewOptions()
+	opts.Logger = l
+	opts.SkipPaths = []string{"/healthz"}
+	opts.Headers = []string{"User-Agent"}
+
+	h := Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger.FromContext(r.Context()).Infow("handling request")
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}), opts)
+
+	req := httptest.NewRequest(http.MethodGet, "/missing", nil)
+	req.Header.Set(logger.KeyRequestID, "req-1")
+	req.Header.Set("User-Agent", "test-agent")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if got := rec.Header().Get(logger.KeyRequestID); got != "req-1" {
+		t.Errorf("request id not propagated, got %q", got)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Header().Get(logger.KeyRequestID) == "" {
+		t.Error("request id not generated")
+	}
+
+	out := output()
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 2 handler logs and 1 access log, got %q", out)
+	}
+	for _, want := range []string{`"X-Request-ID":"req-1"`, `"status":404`, `"level":"WARN"`, `"header.user-agent":"test-agent"`} {
+		if !strings.Contains(lines[1], want) {
+			t.Errorf("missing %s in access log %s", want, lines[1])
+		}
+	}
+	if !strings.Contains(lines[0], `"X-Request-ID":"req-1"`) {
+		t.Errorf("request-scoped logger lacks the request id: %s", lines[0])
+	}
+	if strings.Contains(out, "/healthz") {
+		t.Errorf("skipped path was logged: %s", out)
+	}
+}
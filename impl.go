@@ -23,10 +23,10 @@ const (
 type logger struct {
 	ctx       context.Context
 	options   *Options
+	controls  *controls
 	logger    *zap.SugaredLogger
 	zapLogger *zap.Logger
 	fields    []interface{}
-	skipInit  bool
 	tracing   recordingType
 
 	infoLogger
@@ -36,6 +36,19 @@ func (l *logger) Flush() {
 	_ = l.zapLogger.Sync()
 }
 
+// SetRateLimit changes the entries per second and the burst of every rate
+// limit key. It returns false when the logger was built without
+// Options.RateLimit.By.
+func (l *logger) SetRateLimit(rate float64, burst int) bool {
+	if l.controls == nil || l.controls.limiter == nil {
+		return false
+	}
+	l.controls.limiter.set(rate, burst)
+	return true
+}
+
+var _ Logger = (*logger)(nil)
+
 type infoLogger struct {
 	level zapcore.Level
 	log   *zap.Logger
@@ -43,12 +56,30 @@ type infoLogger struct {
 
 func (l *infoLogger) Enabled() bool { return true }
 
-func (l *infoLogger) Info(msg string, fields ...interface{}) {
+func (l *infoLogger) Info(msg string, keysAndValues ...interface{}) {
+	msg, keysAndValues = infoArgs(msg, keysAndValues)
 	if checkedEntry := l.log.Check(l.level, msg); checkedEntry != nil {
-		checkedEntry.Write(handleFields(l.log, fields)...)
+		checkedEntry.Write(handleFields(l.log, keysAndValues)...)
 	}
 }
 
+// infoArgs returns the message and key-value pairs of an Info call. When the
+// arguments are not key-value pairs they are formatted into the message like
+// fmt.Sprint, which keeps calls written for the Sprint-like Info working.
+func infoArgs(msg string, args []interface{}) (string, []interface{}) {
+	for i := 0; i < len(args); {
+		if _, ok := args[i].(Field); ok {
+			i++
+			continue
+		}
+		if _, ok := args[i].(string); !ok || i == len(args)-1 {
+			return fmt.Sprint(append([]interface{}{msg}, args...)...), nil
+		}
+		i += 2
+	}
+	return msg, args
+}
+
 func (l *infoLogger) Infow(msg string, keysAndValues ...interface{}) {
 	if checkedEntry := l.log.Check(l.level, msg); checkedEntry != nil {
 		checkedEntry.Write(handleFields(l.log, keysAndValues)...)
@@ -61,10 +92,17 @@ func (l *infoLogger) Infof(format string, args ...interface{}) {
 	}
 }
 
+// NewLogger wraps l. Like the loggers of New, it reports the caller of its
+// methods, so l itself should not skip any caller frame.
 func NewLogger(l *zap.Logger) *logger {
+	l = l.WithOptions(zap.AddCallerSkip(1))
 	return &logger{
 		logger:    l.Sugar(),
 		zapLogger: l,
+		infoLogger: infoLogger{
+			log:   l,
+			level: zap.InfoLevel,
+		},
 	}
 }
 
@@ -93,8 +131,10 @@ func (l *logger) Debug(args ...interface{}) {
 	l.logger.Debug(args...)
 }
 
-func (l *logger) Info(mgs string, args ...interface{}) {
-	l.logger.Infow(mgs, args)
+func (l *logger) Info(msg string, keysAndValues ...interface{}) {
+	msg, keysAndValues = infoArgs(msg, keysAndValues)
+	keysAndValues = l.tracingEvent(zapcore.InfoLevel, msg, keysAndValues...)
+	l.logger.Infow(msg, keysAndValues...)
 }
 
 func (l *logger) Warn(args ...interface{}) {
@@ -180,8 +220,9 @@ func (l *logger) Fatalw(msg string, keysAndValues ...interface{}) {
 	l.logger.Fatalw(msg, keysAndValues...)
 }
 
+// Log, Logf and Logw dispatch on every Level. A value outside the defined
+// levels is logged at info.
 func (l *logger) Log(level Level, args ...interface{}) {
-	// nolint: exhaustive
 	switch level {
 	case DebugLevel:
 		l.logger.Debug(args...)
@@ -191,6 +232,12 @@ func (l *logger) Log(level Level, args ...interface{}) {
 		l.logger.Warn(args...)
 	case ErrorLevel:
 		l.logger.Error(args...)
+	case DPanicLevel:
+		l.logger.DPanic(args...)
+	case PanicLevel:
+		l.logger.Panic(args...)
+	case FatalLevel:
+		l.logger.Fatal(args...)
 	default:
 		l.logger.Info(args...)
 	}
@@ -201,7 +248,6 @@ func (l *logger) Enabled() bool {
 }
 
 func (l *logger) Logf(level Level, template string, args ...interface{}) {
-	// nolint: exhaustive
 	switch level {
 	case DebugLevel:
 		l.logger.Debugf(template, args...)
@@ -211,24 +257,35 @@ func (l *logger) Logf(level Level, template string, args ...interface{}) {
 		l.logger.Warnf(template, args...)
 	case ErrorLevel:
 		l.logger.Errorf(template, args...)
+	case DPanicLevel:
+		l.logger.DPanicf(template, args...)
+	case PanicLevel:
+		l.logger.Panicf(template, args...)
+	case FatalLevel:
+		l.logger.Fatalf(template, args...)
 	default:
 		l.logger.Infof(template, args...)
 	}
 }
 
 func (l *logger) Logw(level Level, msg string, keysAndValues ...interface{}) {
-	// nolint: exhaustive
 	switch level {
 	case DebugLevel:
-		l.logger.Debugw(msg, keysAndValues...)
+		l.logger.Debugw(msg, l.tracingEvent(zapcore.DebugLevel, msg, keysAndValues...)...)
 	case InfoLevel:
-		l.logger.Infow(msg, keysAndValues...)
+		l.logger.Infow(msg, l.tracingEvent(zapcore.InfoLevel, msg, keysAndValues...)...)
 	case WarnLevel:
-		l.logger.Warnw(msg, keysAndValues...)
+		l.logger.Warnw(msg, l.tracingEvent(zapcore.WarnLevel, msg, keysAndValues...)...)
 	case ErrorLevel:
-		l.logger.Errorw(msg, keysAndValues...)
+		l.logger.Errorw(msg, l.tracingEvent(zapcore.ErrorLevel, msg, keysAndValues...)...)
+	case DPanicLevel:
+		l.logger.DPanicw(msg, l.tracingEvent(zapcore.DPanicLevel, msg, keysAndValues...)...)
+	case PanicLevel:
+		l.logger.Panicw(msg, l.tracingEvent(zapcore.PanicLevel, msg, keysAndValues...)...)
+	case FatalLevel:
+		l.logger.Fatalw(msg, l.tracingEvent(zapcore.FatalLevel, msg, keysAndValues...)...)
 	default:
-		l.logger.Infow(msg, keysAndValues...)
+		l.logger.Infow(msg, l.tracingEvent(zapcore.InfoLevel, msg, keysAndValues...)...)
 	}
 }
 
@@ -240,6 +297,8 @@ func (l *logger) With(keyValues ...interface{}) Logger {
 	return l.WithCallerSkip(l.ctx, defaultCallerSkip, l.tracing, keyValues...)
 }
 
+// WithSkip returns a logger skipping callerSkip more frames when reporting
+// the caller, for logging helpers that wrap it.
 func (l *logger) WithSkip(callerSkip int, keyValues ...interface{}) Logger {
 	return l.WithCallerSkip(l.ctx, callerSkip, l.tracing, keyValues...)
 }
@@ -253,7 +312,7 @@ func (l *logger) L(ctx context.Context) *logger {
 		lg = lg.WithCallerSkip(l.ctx, defaultCallerSkip, l.tracing, KeyUsername, username).(*logger)
 	}
 	if watcherName := ctx.Value(KeyWatcherName); watcherName != nil {
-		lg = lg.WithCallerSkip(l.ctx, defaultCallerSkip, l.tracing, KeyWatcherName, KeyWatcherName).(*logger)
+		lg = lg.WithCallerSkip(l.ctx, defaultCallerSkip, l.tracing, KeyWatcherName, watcherName).(*logger)
 	}
 	if traceId := ctx.Value(KeyTraceID); traceId != nil {
 		lg = lg.WithCallerSkip(l.ctx, defaultCallerSkip, l.tracing, KeyTraceID, traceId).(*logger)
@@ -272,30 +331,43 @@ const (
 	TraceEvent  recordingType = 1
 )
 
-const defaultCallerSkip = -1
+// defaultCallerSkip keeps the caller skip of the parent. Every logger skips
+// the frame of its own method, so that the package functions, the Logger
+// methods, V and Desugared all report the user's call site.
+const defaultCallerSkip = 0
 
+// WithCallerSkip derives a logger with the given context, tracing mode and
+// fields, skipping callerSkip more frames than l when reporting the caller.
 func (l *logger) WithCallerSkip(ctx context.Context, callerSkip int, tracing recordingType, keyValues ...interface{}) Logger {
-	newFields := make([]interface{}, len(l.fields), len(l.fields)+len(keyValues))
-	copy(newFields, l.fields)
-	newFields = append(newFields, keyValues...)
-	sugar := l.logger.With(keyValues...)
-	zaplogger := l.zapLogger
-
-	// only first With need skip caller, be aware DO NOT affect parent logger
-	if !l.skipInit && callerSkip != 0 {
-		zaplogger = l.logger.With(keyValues...).Desugar().WithOptions(zap.AddCallerSkip(callerSkip))
-		sugar = zaplogger.Sugar()
+	zapLogger := l.zapLogger
+	if callerSkip != 0 {
+		zapLogger = zapLogger.WithOptions(zap.AddCallerSkip(callerSkip))
 	}
+	return l.derive(ctx, tracing, zapLogger, keyValues)
+}
 
-	newLogger := &logger{
-		ctx:       ctx,
-		fields:    newFields,
-		logger:    sugar,
-		zapLogger: zaplogger,
-		skipInit:  true,
-		tracing:   tracing,
+// derive is the one path creating child loggers. The child keeps the
+// options, runtime controls, fields and V level of l, with its own context,
+// tracing mode and zap logger, plus keyValues as additional fields.
+func (l *logger) derive(ctx context.Context, tracing recordingType, zapLogger *zap.Logger, keyValues []interface{}) *logger {
+	sugar := l.logger
+	if zapLogger != l.zapLogger {
+		sugar = zapLogger.Sugar()
+	}
+	child := l.clone()
+	child.ctx = ctx
+	child.tracing = tracing
+	if len(keyValues) > 0 {
+		fields := make([]interface{}, len(l.fields), len(l.fields)+len(keyValues))
+		copy(fields, l.fields)
+		child.fields = append(fields, keyValues...)
+		sugar = sugar.With(keyValues...)
+		zapLogger = sugar.Desugar()
 	}
-	return newLogger
+	child.zapLogger = zapLogger
+	child.logger = sugar
+	child.infoLogger = infoLogger{level: l.infoLogger.level, log: zapLogger}
+	return child
 }
 
 func (l *logger) WithTraceID(ctx context.Context, keyValues ...interface{}) Logger {
@@ -306,58 +378,135 @@ func (l *logger) GetZapLogger() *zap.Logger {
 	return l.zapLogger
 }
 
+// traceKeys returns the field names of the trace and span ids for the
+// schema the logger was built with.
+func (l *logger) traceKeys() (traceIDKey, spanIDKey string) {
+	var schema Schema
+	if l.options != nil {
+		schema = l.options.Schema
+	}
+	preset, err := schema.preset()
+	if err != nil {
+		return KeyTraceID, ""
+	}
+	return preset.traceIDKey, preset.spanIDKey
+}
+
+// traceID returns the value of the trace id field. Cloud Logging correlates
+// the entries with the resource name of the trace, not with its bare id, which
+// is written when no project is configured.
+func (l *logger) traceID(id trace.TraceID) string {
+	if l.options != nil && l.options.Schema.is(SchemaGCP) && l.options.GCPProject != "" {
+		return "projects/" + l.options.GCPProject + "/traces/" + id.String()
+	}
+	return id.String()
+}
+
+// field converts a key-value pair into the field the core will encode.
+func (l *logger) field(key string, val interface{}) zapcore.Field {
+	return l.typedField(zap.Any(key, val))
+}
+
+// typedField returns the field as the core will encode it.
+func (l *logger) typedField(f Field) Field {
+	if l.options != nil && l.options.ErrorEncoding == errorEncodingRich {
+		return richErrorField(f)
+	}
+	return f
+}
+
 func (l *logger) tracingEvent(lvl zapcore.Level, msg string, keysAndValues ...interface{}) []interface{} {
-	if l.ctx == nil {
+	span := l.recordingSpan()
+	if span == nil {
 		return keysAndValues
 	}
+	if l.tracing == TraceEvent {
+		attrs := l.eventAttrs(span, lvl, msg)
+		attrs = l.appendArgs(attrs, keysAndValues)
+		span.AddEvent("log", trace.WithAttributes(attrs...))
+	}
+
+	traceIDKey, spanIDKey := l.traceKeys()
+	if s := span.SpanContext(); s.HasTraceID() {
+		// keysAndValues = append([]interface{}{"trace_id", s.TraceID().String()}, keysAndValues...)
+		keysAndValues = append(keysAndValues, traceIDKey, l.traceID(s.TraceID()))
+		if spanIDKey != "" && s.HasSpanID() {
+			keysAndValues = append(keysAndValues, spanIDKey, s.SpanID().String())
+		}
+	}
+	return keysAndValues
+}
+
+// tracingFields is tracingEvent for typed fields.
+func (l *logger) tracingFields(lvl zapcore.Level, msg string, fields []Field) []Field {
+	span := l.recordingSpan()
+	if span == nil {
+		return fields
+	}
+	if l.tracing == TraceEvent {
+		attrs := l.eventAttrs(span, lvl, msg)
+		for _, f := range fields {
+			attrs = appendField(attrs, l.typedField(f))
+		}
+		span.AddEvent("log", trace.WithAttributes(attrs...))
+	}
+
+	traceIDKey, spanIDKey := l.traceKeys()
+	if s := span.SpanContext(); s.HasTraceID() {
+		fields = append(fields[:len(fields):len(fields)], zap.String(traceIDKey, l.traceID(s.TraceID())))
+		if spanIDKey != "" && s.HasSpanID() {
+			fields = append(fields, zap.String(spanIDKey, s.SpanID().String()))
+		}
+	}
+	return fields
+}
+
+// recordingSpan returns the span of the logger context when it is recording.
+func (l *logger) recordingSpan() trace.Span {
+	if l.ctx == nil {
+		return nil
+	}
 	span := trace.SpanFromContext(l.ctx)
-	if span.IsRecording() {
-		if l.tracing == TraceEvent {
-			if zapcore.ErrorLevel.Enabled(lvl) {
-				span.SetStatus(codes.Error, msg)
-			}
-			attrs := make([]attribute.KeyValue, 0)
-			attrs = append(attrs, logSeverityKey.String(levelString(lvl)))
-			attrs = append(attrs, logMessageKey.String(msg))
-			for i := 0; i < len(l.fields); {
-				// Make sure this element isn't a dangling key.
-				if i == len(l.fields)-1 {
-					break
-				}
-
-				// Consume this value and the next, treating them as a key-value pair. If the
-				// key isn't a string, add this pair to the slice of invalid pairs.
-				key, val := l.fields[i], l.fields[i+1]
-				if keyStr, ok := key.(string); ok {
-					f := zap.Any(keyStr, val)
-					attrs = appendField(attrs, f)
-				}
-				i += 2
-			}
-
-			for i := 0; i < len(keysAndValues); {
-				// Make sure this element isn't a dangling key.
-				if i == len(keysAndValues)-1 {
-					break
-				}
-
-				// Consume this value and the next, treating them as a key-value pair. If the
-				// key isn't a string, add this pair to the slice of invalid pairs.
-				key, val := keysAndValues[i], keysAndValues[i+1]
-				if keyStr, ok := key.(string); ok {
-					f := zap.Any(keyStr, val)
-					attrs = appendField(attrs, f)
-				}
-				i += 2
-			}
-
-			span.AddEvent("log", trace.WithAttributes(attrs...))
+	if !span.IsRecording() {
+		return nil
+	}
+	return span
+}
+
+// eventAttrs marks the span as failed for errors and returns the attributes
+// of a log event with the fields of the logger.
+func (l *logger) eventAttrs(span trace.Span, lvl zapcore.Level, msg string) []attribute.KeyValue {
+	if zapcore.ErrorLevel.Enabled(lvl) {
+		span.SetStatus(codes.Error, msg)
+	}
+	attrs := make([]attribute.KeyValue, 0)
+	attrs = append(attrs, logSeverityKey.String(levelString(lvl)))
+	attrs = append(attrs, logMessageKey.String(msg))
+	return l.appendArgs(attrs, l.fields)
+}
+
+// appendArgs adds key-value pairs and inline Fields, as the sugared logger
+// accepts them, to the span attributes.
+func (l *logger) appendArgs(attrs []attribute.KeyValue, args []interface{}) []attribute.KeyValue {
+	for i := 0; i < len(args); {
+		if f, ok := args[i].(Field); ok {
+			attrs = appendField(attrs, l.typedField(f))
+			i++
+			continue
 		}
 
-		if s := span.SpanContext(); s.HasTraceID() {
-			// keysAndValues = append([]interface{}{"trace_id", s.TraceID().String()}, keysAndValues...)
-			keysAndValues = append(keysAndValues, "trace_id", s.TraceID().String())
+		// Make sure this element isn't a dangling key.
+		if i == len(args)-1 {
+			break
 		}
+
+		// Consume this value and the next, treating them as a key-value pair. If the
+		// key isn't a string, add this pair to the slice of invalid pairs.
+		key, val := args[i], args[i+1]
+		if keyStr, ok := key.(string); ok {
+			attrs = appendField(attrs, l.field(keyStr, val))
+		}
+		i += 2
 	}
-	return keysAndValues
+	return attrs
 }
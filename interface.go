@@ -7,7 +7,10 @@ import (
 )
 
 type InfoLogger interface {
-	Info(mgs string, args ...interface{})
+	// Info logs msg with key-value pairs, the same way on every path. Arguments
+	// that are not key-value pairs are appended to the message, as the former
+	// package-level Info(args ...interface{}) did.
+	Info(msg string, keysAndValues ...interface{})
 	Infof(format string, v ...interface{})
 	Infow(msg string, keysAndValues ...interface{})
 
@@ -20,6 +23,8 @@ type Logger interface {
 	Debug(args ...interface{})
 	Warn(args ...interface{})
 	Error(args ...interface{})
+	DPanic(args ...interface{})
+	Panic(args ...interface{})
 	Fatal(args ...interface{})
 
 	// nolint: gofumpt
@@ -27,6 +32,8 @@ type Logger interface {
 
 	Warnf(template string, args ...interface{})
 	Errorf(template string, args ...interface{})
+	DPanicf(template string, args ...interface{})
+	Panicf(template string, args ...interface{})
 	Fatalf(template string, args ...interface{})
 
 	// nolint: gofumpt
@@ -34,6 +41,8 @@ type Logger interface {
 
 	Warnw(msg string, keysAndValues ...interface{})
 	Errorw(msg string, keysAndValues ...interface{})
+	DPanicw(msg string, keysAndValues ...interface{})
+	Panicw(msg string, keysAndValues ...interface{})
 	Fatalw(msg string, keysAndValues ...interface{})
 
 	// nolint: gofumpt
@@ -47,5 +56,8 @@ type Logger interface {
 	WithTraceID(ctx context.Context, keyValues ...interface{}) Logger
 	WithContext(ctx context.Context) context.Context
 	WithName(name string) Logger
+	// Desugared returns the view of the logger taking typed Fields.
+	Desugared() FieldLogger
 	Flush()
+	Sync() error
 }
@@ -2,6 +2,9 @@ package klog
 
 import (
 	"flag"
+	"io"
+	"os"
+	"sync"
 
 	"go.uber.org/zap"
 	"k8s.io/klog"
@@ -9,15 +12,63 @@ import (
 
 // InitLogger init klog by zap logger.
 func InitLogger(zapLogger *zap.Logger) {
+	Install(zapLogger)
+}
+
+// installed holds the writers of the last Install still in place, nil while
+// klog writes on its own.
+var (
+	mu        sync.Mutex
+	installed []severityWriter
+)
+
+type severityWriter struct {
+	severity string
+	w        io.Writer
+}
+
+// Install redirects klog to the zap logger and returns a function restoring
+// the klog flags and writers in place before the call.
+func Install(zapLogger *zap.Logger) (undo func()) {
 	fs := flag.NewFlagSet("klog", flag.ExitOnError)
 	klog.InitFlags(fs)
 	defer klog.Flush()
-	klog.SetOutputBySeverity("INFO", &infoLogger{logger: zapLogger})
-	klog.SetOutputBySeverity("WARNING", &warnLogger{logger: zapLogger})
-	klog.SetOutputBySeverity("FATAL", &fatalLogger{logger: zapLogger})
-	klog.SetOutputBySeverity("ERROR", &errorLogger{logger: zapLogger})
+	skipHeaders, toStderr := fs.Lookup("skip_headers").Value.String(), fs.Lookup("logtostderr").Value.String()
+
+	writers := []severityWriter{
+		{"INFO", &infoLogger{logger: zapLogger}},
+		{"WARNING", &warnLogger{logger: zapLogger}},
+		{"FATAL", &fatalLogger{logger: zapLogger}},
+		{"ERROR", &errorLogger{logger: zapLogger}},
+	}
+	mu.Lock()
+	prev := installed
+	installed = writers
+	mu.Unlock()
+	setOutputs(writers)
 	_ = fs.Set("skip_headers", "true")
 	_ = fs.Set("logtostderr", "false")
+	return func() {
+		klog.Flush()
+		mu.Lock()
+		installed = prev
+		mu.Unlock()
+		setOutputs(prev)
+		_ = fs.Set("skip_headers", skipHeaders)
+		_ = fs.Set("logtostderr", toStderr)
+	}
+}
+
+// setOutputs sets the writers of klog. Its log files cannot be set back, so
+// without writers every severity goes to stderr, as with logtostderr.
+func setOutputs(writers []severityWriter) {
+	if writers == nil {
+		klog.SetOutput(os.Stderr)
+		return
+	}
+	for _, sw := range writers {
+		klog.SetOutputBySeverity(sw.severity, sw.w)
+	}
 }
 
 type infoLogger struct {
@@ -3,7 +3,6 @@ package logger
 import (
 	"sync"
 
-	"github.com/costa92/logger/klog"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
@@ -20,12 +19,11 @@ func handleFields(l *zap.Logger, args []interface{}, additional ...zap.Field) []
 	// little less space.
 	fields := make([]zap.Field, 0, len(args)/2+len(additional))
 	for i := 0; i < len(args); {
-		// check just in case for strongly-typed Zap fields, which is illegal (since
-		// it breaks implementation agnosticism), so we can give a better error message.
-		if _, ok := args[i].(zap.Field); ok {
-			l.DPanic("strongly-typed Zap Field passed to logr", zap.Any("zap field", args[i]))
-
-			break
+		// strongly-typed fields are accepted inline, as the sugared logger does.
+		if f, ok := args[i].(zap.Field); ok {
+			fields = append(fields, f)
+			i++
+			continue
 		}
 
 		// make sure this isn't a mismatched key
@@ -59,14 +57,25 @@ func handleFields(l *zap.Logger, args []interface{}, additional ...zap.Field) []
 var (
 	std = New(NewOptions())
 	mu  sync.Mutex
+	// undoGlobals undoes the InstallGlobals of the last Init.
+	undoGlobals func()
 )
 
+// Init replaces the global logger and installs it as the output of the
+// standard library log package, klog and zap as opts asks, undoing what the
+// previous Init installed.
 func Init(opts *Options) {
 	mu.Lock()
 	defer mu.Unlock()
 	std = New(opts)
+	if undoGlobals != nil {
+		undoGlobals()
+	}
+	undoGlobals = std.InstallGlobals()
 }
 
+// New builds a logger from opts. It leaves the global loggers of the process
+// alone, Init and InstallGlobals install a logger there.
 func New(opts *Options) *logger {
 	if opts == nil {
 		opts = NewOptions()
@@ -75,23 +84,24 @@ func New(opts *Options) *logger {
 	if err := zapLevel.UnmarshalText([]byte(opts.Level)); err != nil {
 		zapLevel = zapcore.InfoLevel
 	}
-	encodeLevel := zapcore.CapitalLevelEncoder
-	// when output to local path, with color is forbidden
-	if opts.Format == consoleFormat && opts.EnableColor {
-		encodeLevel = zapcore.CapitalColorLevelEncoder
+	preset, err := opts.Schema.preset()
+	if err != nil {
+		preset, _ = SchemaDefault.preset()
 	}
-	encoderConfig := zapcore.EncoderConfig{
-		MessageKey:     "message",
-		LevelKey:       "level",
-		TimeKey:        "timestamp",
-		NameKey:        "logger",
-		CallerKey:      "caller",
-		StacktraceKey:  "stacktrace",
-		LineEnding:     zapcore.DefaultLineEnding,
-		EncodeLevel:    encodeLevel,
-		EncodeTime:     timeEncoder,
-		EncodeDuration: milliSecondsDurationEncoder,
-		EncodeCaller:   zapcore.ShortCallerEncoder,
+	// when output to local path, with color is forbidden, so every output
+	// decides on its own whether it is colored
+	encoderConfig := func(color bool) zapcore.EncoderConfig {
+		return opts.Encoder.encoderConfig(preset, color)
+	}
+	initialFields := opts.FieldPair
+	if len(preset.fields) > 0 {
+		initialFields = make(map[string]interface{}, len(preset.fields)+len(opts.FieldPair))
+		for k, v := range preset.fields {
+			initialFields[k] = v
+		}
+		for k, v := range opts.FieldPair {
+			initialFields[k] = v
+		}
 	}
 	loggerConfig := &zap.Config{
 		Level:             zap.NewAtomicLevelAt(zapLevel),
@@ -102,15 +112,17 @@ func New(opts *Options) *logger {
 			Initial:    100,
 			Thereafter: 100,
 		},
-		Encoding:         opts.Format,
-		EncoderConfig:    encoderConfig,
-		OutputPaths:      opts.OutputPaths,
 		ErrorOutputPaths: opts.ErrorOutputPaths,
-		InitialFields:    opts.FieldPair,
+		InitialFields:    initialFields,
+	}
+
+	if opts.DisableSampling {
+		loggerConfig.Sampling = nil
+	} else if opts.Metrics != nil {
+		loggerConfig.Sampling.Hook = samplingHook(opts.Metrics)
 	}
 
-	var err error
-	log, err := loggerConfig.Build(
+	log, ctrl, err := buildLogger(opts, loggerConfig, encoderConfig,
 		zap.AddStacktrace(zapcore.PanicLevel),
 		zap.AddCallerSkip(1),
 	)
@@ -127,14 +139,12 @@ func New(opts *Options) *logger {
 		logger:    log.Sugar(),
 		fields:    fieldPair,
 		options:   opts,
-		skipInit:  true,
+		controls:  ctrl,
 		infoLogger: infoLogger{
 			log:   log,
 			level: zap.InfoLevel,
 		},
 	}
-	klog.InitLogger(log)
-	zap.RedirectStdLog(log)
 	return logger
 }
 
@@ -149,6 +159,5 @@ func Flush() {
 func WithName(s string) Logger { return std.WithName(s) }
 
 func (l *logger) WithName(name string) Logger {
-	newLogger := l.zapLogger.Named(name)
-	return NewLogger(newLogger)
+	return l.derive(l.ctx, l.tracing, l.zapLogger.Named(name), nil)
 }
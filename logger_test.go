@@ -1,8 +1,28 @@
 package logger
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
 	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"k8s.io/klog"
 )
 
 func show() {
@@ -131,3 +151,1031 @@ func TestColorLogger(t *testing.T) {
 //
 //	show()
 //}
+
+// newTestLogger builds a logger from opts writing to a temporary file.
+// output flushes the logger and returns the lines written so far.
+func newTestLogger(t *testing.T, opts *Options) (l *logger, output func() []string) {
+	path := testOutputPath(t, opts)
+	l = New(opts)
+	return l, func() []string {
+		l.Flush()
+		return readLines(t, path)
+	}
+}
+
+// initTestLogger is newTestLogger for the global logger, installed by Init.
+func initTestLogger(t *testing.T, opts *Options) (output func() []string) {
+	path := testOutputPath(t, opts)
+	Init(opts)
+	return func() []string {
+		Flush()
+		return readLines(t, path)
+	}
+}
+
+// testOutputPath sets the output of opts to a temporary file and returns
+// its path.
+func testOutputPath(t *testing.T, opts *Options) string {
+	path := filepath.Join(t.TempDir(), "out.log")
+	opts.OutputPaths = []string{path}
+	return path
+}
+
+// readLines returns the lines of the file at path.
+func readLines(t *testing.T, path string) []string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
+
+// matchLines fails t unless every line matches the expression of its index.
+func matchLines(t *testing.T, lines []string, expected []string) {
+	t.Helper()
+	if len(lines) != len(expected) {
+		t.Fatalf("expected %d lines, got %q", len(expected), lines)
+	}
+	for i, want := range expected {
+		if !regexp.MustCompile(want).MatchString(lines[i]) {
+			t.Errorf("expected %s, got %s", want, lines[i])
+		}
+	}
+}
+
+func TestSchema(t *testing.T) {
+	tests := []struct {
+		schema Schema
+		keys   []string
+		// trace is the key and value of the trace id field.
+		trace [2]string
+	}{
+		{SchemaDefault, []string{"message", "level", "timestamp", "caller"}, [2]string{"trace_id", "01000000000000000000000000000000"}},
+		{SchemaECS, []string{"message", "log.level", "@timestamp", "ecs.version", "log.origin"}, [2]string{"trace.id", "01000000000000000000000000000000"}},
+		{SchemaGELF, []string{"short_message", "level", "timestamp", "version", "host"}, [2]string{"_trace_id", "01000000000000000000000000000000"}},
+		{SchemaGCP, []string{"message", "severity", "time"}, [2]string{"logging.googleapis.com/trace", "projects/my-project/traces/01000000000000000000000000000000"}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.schema), func(t *testing.T) {
+			opts := NewOptions()
+			opts.Schema = tt.schema
+			opts.GCPProject = "my-project"
+			if errs := opts.Validate(); len(errs) > 0 {
+				t.Fatal(errs)
+			}
+			l, output := newTestLogger(t, opts)
+			l.Ctx(recordingContext()).Infow("schema message", "da", "123")
+
+			lines := output()
+			var entry map[string]interface{}
+			if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+				t.Fatalf("invalid json %q: %v", lines, err)
+			}
+			for _, key := range tt.keys {
+				if _, ok := entry[key]; !ok {
+					t.Errorf("missing key %q in %s", key, lines[0])
+				}
+			}
+			if got := entry[tt.trace[0]]; got != tt.trace[1] {
+				t.Errorf("expected trace %s, got %v in %s", tt.trace[1], got, lines[0])
+			}
+		})
+	}
+
+	// without a project the trace id is written as is
+	opts := NewOptions()
+	opts.Schema = SchemaGCP
+	opts.GCPProject = ""
+	if errs := opts.Validate(); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	l, output := newTestLogger(t, opts)
+	l.Ctx(recordingContext()).Infow("schema message")
+	if line := output()[0]; !strings.Contains(line, `"logging.googleapis.com/trace":"01000000000000000000000000000000"`) {
+		t.Errorf("expected the bare trace id in %s", line)
+	}
+	// ecs splits the caller into the file name, line and function
+	opts = NewOptions()
+	opts.Schema = SchemaECS
+	l, output = newTestLogger(t, opts)
+	l.Infow("schema message")
+	line := callerLine() - 1
+	matchLines(t, output(), []string{
+		`"log.origin":\{"file":\{"name":"[^"]*/logger_test.go","line":` + strconv.Itoa(line) + `\},"function":"[^"]*\.TestSchema"\}`,
+	})
+	// gelf prefixes the keys of the additional fields
+	opts = NewOptions()
+	opts.Schema = SchemaGELF
+	opts.FieldPair = map[string]interface{}{"region": "eu"}
+	l, output = newTestLogger(t, opts)
+	l.With("user", "u1").Infow("schema message", "da", "123", "_id2", "x")
+	entry := output()[0]
+	for _, want := range []string{`"version":"1.1"`, `"_region":"eu"`, `"_user":"u1"`, `"_da":"123"`, `"_id2":"x"`} {
+		if !strings.Contains(entry, want) {
+			t.Errorf("missing %s in %s", want, entry)
+		}
+	}
+}
+
+func TestEncoderOptions(t *testing.T) {
+	opts := NewOptions()
+	opts.Encoder = EncoderOptions{
+		MessageKey:   "msg",
+		TimeKey:      "ts",
+		TimeLayout:   "epoch-millis",
+		DurationUnit: "string",
+		LevelCase:    "lower",
+	}
+	if errs := opts.Validate(); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	l, output := newTestLogger(t, opts)
+	l.Infow("encoder message", "elapsed", time.Second)
+
+	data := output()[0]
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(data), &entry); err != nil {
+		t.Fatalf("invalid json %q: %v", data, err)
+	}
+	if entry["msg"] != "encoder message" || entry["level"] != "info" || entry["elapsed"] != "1s" {
+		t.Errorf("unexpected entry %s", data)
+	}
+	if _, ok := entry["ts"].(float64); !ok {
+		t.Errorf("expected epoch millis in %s", data)
+	}
+
+	cfg := NewProductionConfig()
+	cfg.Encoder = opts.Encoder
+	if got := cfg.newCustomEncoderConfig(); got.MessageKey != "msg" || got.TimeKey != "ts" {
+		t.Errorf("config does not share encoder options: %+v", got)
+	}
+}
+
+func TestPrettyEncoder(t *testing.T) {
+	enc := newPrettyEncoder(zap.NewDevelopmentEncoderConfig(), false)
+	buf, err := enc.EncodeEntry(zapcore.Entry{
+		Level:      zapcore.WarnLevel,
+		LoggerName: "worker",
+		Message:    "pretty message",
+	}, []Field{
+		String("user", "bob smith"),
+		Int("attempt", 3),
+		Err(errors.New("boom")),
+		Any("payload", map[string]int{"a": 1}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{"WARN", "worker", "pretty message", `user="bob smith"`, "attempt=3", `error=boom`, "payload=\n", `"a": 1`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in %q", want, out)
+		}
+	}
+	if strings.Contains(out, "\x1b[") {
+		t.Errorf("unexpected color in %q", out)
+	}
+}
+
+func TestColorFileOutput(t *testing.T) {
+	opts := NewOptions()
+	opts.Format = consoleFormat
+	opts.EnableColor = true
+	l, output := newTestLogger(t, opts)
+	l.Infow("\x1b[31mred\x1b[0m message")
+
+	if data := output()[0]; strings.Contains(data, "\x1b[") || !strings.Contains(data, "INFO") {
+		t.Errorf("unexpected file output %q", data)
+	}
+
+	opts.Color = colorAlways
+	if sinks := opts.outputSinks(zapcore.InfoLevel); !sinks[0].color {
+		t.Errorf("color mode %q should color %s", opts.Color, opts.OutputPaths[0])
+	}
+}
+
+func TestSinks(t *testing.T) {
+	dir := t.TempDir()
+	infoPath, errorPath := filepath.Join(dir, "info.log"), filepath.Join(dir, "error.log")
+	opts := NewOptions()
+	opts.Level = "debug"
+	opts.Sinks = []SinkOptions{
+		{Path: infoPath, Format: jsonFormat, MinLevel: "info", MaxLevel: "warn"},
+		{Path: errorPath, Format: consoleFormat, MinLevel: "error"},
+	}
+	if errs := opts.Validate(); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	l := New(opts)
+	l.Debugw("debug message")
+	l.Infow("info message")
+	l.Errorw("error message")
+	l.Flush()
+
+	info, _ := os.ReadFile(infoPath)
+	errorLog, _ := os.ReadFile(errorPath)
+	if !strings.Contains(string(info), "info message") ||
+		strings.Contains(string(info), "debug message") || strings.Contains(string(info), "error message") {
+		t.Errorf("unexpected info sink output %q", info)
+	}
+	if !strings.Contains(string(errorLog), "error message") || strings.Contains(string(errorLog), "info message") {
+		t.Errorf("unexpected error sink output %q", errorLog)
+	}
+}
+
+func TestHooks(t *testing.T) {
+	var observed []string
+	opts := NewOptions()
+	opts.EntryHooks = []EntryHook{
+		func(ent *Entry, fields []Field) ([]Field, bool) {
+			for _, f := range fields {
+				if f.Key == "secret" {
+					return nil, false
+				}
+			}
+			ent.Message = strings.ToUpper(ent.Message)
+			return append(fields, String("region", "eu")), true
+		},
+	}
+	opts.Hooks = []func(Entry, []Field) error{
+		func(ent Entry, fields []Field) error {
+			observed = append(observed, ent.Message)
+			return nil
+		},
+	}
+	l, output := newTestLogger(t, opts)
+	l.With("tenant", "t1").Infow("hooked message")
+	l.Infow("vetoed message", "secret", "s3cr3t")
+
+	out := strings.Join(output(), "\n")
+	if !strings.Contains(out, `"message":"HOOKED MESSAGE","tenant":"t1","region":"eu"`) {
+		t.Errorf("entry not enriched: %s", out)
+	}
+	if strings.Contains(out, "s3cr3t") {
+		t.Errorf("vetoed entry was written: %s", out)
+	}
+	if len(observed) != 1 || observed[0] != "HOOKED MESSAGE" {
+		t.Errorf("unexpected observed entries %v", observed)
+	}
+}
+
+type stackError struct{ msg string }
+
+func (e *stackError) Error() string           { return e.msg }
+func (e *stackError) StackTrace() stackFrames { return stackFrames{"main.go:10"} }
+
+type stackFrames []string
+
+func (f stackFrames) Format(s fmt.State, verb rune) { fmt.Fprint(s, strings.Join(f, "\n")) }
+
+func TestRichErrorEncoding(t *testing.T) {
+	opts := NewOptions()
+	opts.ErrorEncoding = errorEncodingRich
+	l, output := newTestLogger(t, opts)
+	root := &stackError{msg: "disk full"}
+	err := fmt.Errorf("save: %w", errors.Join(root, errors.New("retry failed")))
+	l.Errorw("rich error", "error", err)
+
+	data := output()[0]
+	var entry struct {
+		Error struct {
+			Message string   `json:"message"`
+			Type    string   `json:"type"`
+			Chain   []string `json:"chain"`
+			Stack   string   `json:"stack"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(data), &entry); err != nil {
+		t.Fatalf("invalid json %q: %v", data, err)
+	}
+	if entry.Error.Message != err.Error() || entry.Error.Type != "*fmt.wrapError" ||
+		len(entry.Error.Chain) != 3 || entry.Error.Stack != "main.go:10" {
+		t.Errorf("unexpected rich error %+v", entry.Error)
+	}
+
+	attrs := appendField(nil, l.field("error", err))
+	if len(attrs) != 4 || attrs[3].Value.AsString() != "main.go:10" {
+		t.Errorf("unexpected span attributes %v", attrs)
+	}
+
+	// a nil pointer in the error is written as zap writes it
+	var nilErr *stackError
+	l.Errorw("nil error", "error", error(nilErr), "wrapped", fmt.Errorf("wrap: %w", error(nilErr)))
+	if line := output()[1]; !strings.Contains(line, `"error":"<nil>","wrapped":{"message":"wrap: <nil>","type":"*fmt.wrapError"}`) {
+		t.Errorf("unexpected nil error encoding %s", line)
+	}
+	if attrs := appendField(nil, l.field("error", error(nilErr))); len(attrs) != 1 || attrs[0].Value.AsString() != "<nil>" {
+		t.Errorf("unexpected span attributes of a nil error %v", attrs)
+	}
+}
+
+func TestDedup(t *testing.T) {
+	opts := NewOptions()
+	opts.Dedup = DedupOptions{Window: time.Hour, MaxEntries: 2}
+	l, output := newTestLogger(t, opts)
+	for i := 0; i < 5; i++ {
+		l.Errorw("retry failed", "attempt", 1)
+	}
+	l.Errorw("retry failed", "attempt", 2)
+	l.Errorw("other failure")
+
+	lines := output()
+	if len(lines) != 4 {
+		t.Fatalf("expected 3 entries and 1 summary, got %q", lines)
+	}
+	// the third distinct entry evicts the first one, which writes its summary
+	if !strings.Contains(lines[2], `"message":"retry failed (repeated 4 times in 1h0m0s)","attempt":1,"repeated":4`) {
+		t.Errorf("unexpected summary %s", lines[2])
+	}
+}
+
+func TestDedupLoggerName(t *testing.T) {
+	opts := NewOptions()
+	opts.Dedup = DedupOptions{Window: time.Hour}
+	l, output := newTestLogger(t, opts)
+	for _, name := range []string{"a", "b"} {
+		l.WithName(name).Errorw("connection lost")
+	}
+	l.Errorw("connection lost")
+	l.Errorw("connection lost")
+
+	if lines := output(); len(lines) != 4 {
+		t.Errorf("expected an entry per logger name and call site, got %q", lines)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	opts := NewOptions()
+	opts.RateLimit = RateLimitOptions{By: rateLimitByField, Field: "tenant_id", Rate: 0.001, Burst: 2}
+	if errs := opts.Validate(); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	l, output := newTestLogger(t, opts)
+	noisy := l.With("tenant_id", "noisy")
+	for i := 0; i < 10; i++ {
+		noisy.Infow("noisy message")
+	}
+	l.Infow("quiet message", "tenant_id", "quiet")
+	l.Flush()
+
+	if !l.SetRateLimit(0, 0) {
+		t.Fatal("rate limit not adjustable at runtime")
+	}
+	for i := 0; i < 3; i++ {
+		noisy.Infow("unlimited message")
+	}
+
+	out := strings.Join(output(), "\n")
+	if n := strings.Count(out, "noisy message"); n != 2 {
+		t.Errorf("expected the burst of 2 noisy entries, got %d", n)
+	}
+	if !strings.Contains(out, "quiet message") || strings.Count(out, "unlimited message") != 3 {
+		t.Errorf("unexpected output %s", out)
+	}
+	if !strings.Contains(out, `"message":"rate limited 8 entries","rate_limit_by":"field","rate_limit_key":"noisy","dropped":8`) {
+		t.Errorf("missing rate limit notice in %s", out)
+	}
+}
+
+func TestRateLimitEviction(t *testing.T) {
+	opts := NewOptions()
+	opts.RateLimit = RateLimitOptions{By: rateLimitByField, Field: "tenant_id", Rate: 0.001, Burst: 1, MaxKeys: 2}
+	l, output := newTestLogger(t, opts)
+	tenants := []string{"a", "b", "c", "d"}
+	for _, tenant := range tenants {
+		for i := 0; i < 3; i++ {
+			l.Infow("noisy message", "tenant_id", tenant)
+		}
+	}
+	if n := len(l.controls.limiter.buckets); n != 2 {
+		t.Errorf("expected 2 buckets, got %d", n)
+	}
+
+	out := strings.Join(output(), "\n")
+	for _, tenant := range tenants {
+		notice := `"rate_limit_key":"` + tenant + `","dropped":2`
+		if n := strings.Count(out, notice); n != 1 {
+			t.Errorf("expected one notice of %s, got %d in %s", tenant, n, out)
+		}
+	}
+}
+
+func TestFlightRecorder(t *testing.T) {
+	opts := NewOptions()
+	opts.FlightRecorder = FlightRecorderOptions{Size: 2}
+	if errs := opts.Validate(); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	l, output := newTestLogger(t, opts)
+	failing := l.L(context.WithValue(context.Background(), KeyRequestID, "failing"))
+	other := l.L(context.WithValue(context.Background(), KeyRequestID, "other"))
+	for i := 0; i < 3; i++ {
+		failing.Debugw("failing step", "step", i)
+		other.Debugw("other step", "step", i)
+	}
+	failing.Infow("written")
+	failing.Errorw("failed")
+
+	lines := output()
+	if len(lines) != 4 {
+		t.Fatalf("expected 4 lines, got %q", lines)
+	}
+	for i, want := range []string{`"written"`, `"failing step","X-Request-ID":"failing","step":1`, `"failing step","X-Request-ID":"failing","step":2`, `"failed"`} {
+		if !strings.Contains(lines[i], want) {
+			t.Errorf("line %d: expected %s in %s", i, want, lines[i])
+		}
+	}
+	if out := strings.Join(lines, "\n"); strings.Contains(out, "other step") {
+		t.Errorf("entries of another request written: %s", out)
+	}
+}
+
+func TestFlightRecorderHooks(t *testing.T) {
+	opts := NewOptions()
+	opts.FlightRecorder = FlightRecorderOptions{Size: 2, Scope: "global"}
+	opts.EntryHooks = []EntryHook{
+		func(ent *Entry, fields []Field) ([]Field, bool) {
+			for i, f := range fields {
+				if f.Key == "password" {
+					fields[i] = String("password", "[redacted]")
+				}
+			}
+			return fields, true
+		},
+	}
+	l, output := newTestLogger(t, opts)
+	l.Debugw("login", "password", "hunter2")
+	l.Errorw("failed", "password", "hunter2")
+
+	lines := output()
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %q", lines)
+	}
+	for _, line := range lines {
+		if !strings.Contains(line, `"password":"[redacted]"`) {
+			t.Errorf("entry not redacted: %s", line)
+		}
+	}
+}
+
+func TestAuditLogger(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	opts := NewAuditOptions()
+	opts.OutputPaths = []string{path}
+	opts.Key = "secret"
+	for _, user := range []string{"alice", "bob"} {
+		audit, err := NewAuditLogger(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, reserved := range [][]interface{}{{"seq", 1}, {Int("seq", 1)}, {String("hash", "forged")}} {
+			if err := audit.Log("forged", reserved...); err == nil {
+				t.Fatalf("reserved audit key %v accepted", reserved)
+			}
+		}
+		for _, action := range []string{"login", "delete"} {
+			if err := audit.Log(action, "user", user, Int("items", 3)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := audit.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyAudit(strings.NewReader(string(data)), opts.Key); err != nil {
+		t.Fatalf("valid audit log rejected: %v", err)
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+	if len(lines) != 5 || !strings.Contains(lines[3], `"seq":4`) {
+		t.Fatalf("unexpected audit log %s", data)
+	}
+
+	tests := map[string]string{
+		"wrong key": string(data),
+		"edited":    strings.Replace(string(data), `"user":"bob"`, `"user":"eve"`, 1),
+		"removed":   lines[0] + lines[2] + lines[3],
+		"truncated": lines[1] + lines[2],
+	}
+	for name, content := range tests {
+		key := opts.Key
+		if name == "wrong key" {
+			key = "guess"
+		}
+		if err := VerifyAudit(strings.NewReader(content), key); !errors.Is(err, ErrAuditTampered) {
+			t.Errorf("%s: expected tampering to be detected, got %v", name, err)
+		}
+	}
+}
+
+func TestDesugared(t *testing.T) {
+	opts := NewOptions()
+	opts.EnableCaller = true
+	l, output := newTestLogger(t, opts)
+	typed := l.Desugared().With(String("component", "typed"))
+	typed.Info("typed message", Int("attempt", 2), Duration("elapsed", time.Second))
+	typed.Debug("disabled message")
+	if typed.Enabled(DebugLevel) || !typed.Enabled(InfoLevel) {
+		t.Error("unexpected enabled levels")
+	}
+	typed.Sugar().Infow("sugared message", "attempt", 3)
+
+	lines := output()
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %q", lines)
+	}
+	if !strings.Contains(lines[0], `/logger_test.go:`) ||
+		!strings.Contains(lines[0], `"message":"typed message","component":"typed","attempt":2,"elapsed":1`) {
+		t.Errorf("unexpected typed entry %s", lines[0])
+	}
+	if !strings.Contains(lines[1], `"message":"sugared message","component":"typed","attempt":3`) {
+		t.Errorf("unexpected sugared entry %s", lines[1])
+	}
+}
+
+func TestLogLevels(t *testing.T) {
+	opts := NewOptions()
+	opts.Level = "debug"
+	l, output := newTestLogger(t, opts)
+
+	levels := []Level{DebugLevel, InfoLevel, WarnLevel, ErrorLevel, DPanicLevel, PanicLevel}
+	for _, lvl := range levels {
+		func() {
+			defer func() {
+				if r := recover(); (r != nil) != (lvl == PanicLevel) {
+					t.Errorf("%s: unexpected panic %v", lvl, r)
+				}
+			}()
+			l.Logw(lvl, "logw", "level", lvl.String())
+		}()
+		func() {
+			defer func() { _ = recover() }()
+			l.Logf(lvl, "logf %s", lvl)
+		}()
+		func() {
+			defer func() { _ = recover() }()
+			l.Log(lvl, "log ", lvl)
+		}()
+	}
+	if err := l.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := output()
+	if len(lines) != 3*len(levels) {
+		t.Fatalf("expected %d lines, got %d", 3*len(levels), len(lines))
+	}
+	for i, lvl := range levels {
+		for j, msg := range []string{"logw", "logf " + lvl.String(), "log " + lvl.String()} {
+			line := lines[3*i+j]
+			if !strings.Contains(line, `"level":"`+lvl.CapitalString()+`"`) || !strings.Contains(line, `"message":"`+msg+`"`) {
+				t.Errorf("expected %s at %s, got %s", msg, lvl, line)
+			}
+		}
+	}
+}
+
+func TestInfoPaths(t *testing.T) {
+	opts := NewOptions()
+	opts.DisableCaller = true
+	output := initTestLogger(t, opts)
+
+	tests := []struct {
+		msg      string
+		args     []interface{}
+		expected string
+	}{
+		{msg: "plain", expected: `"message":"plain"`},
+		{msg: "pairs", args: []interface{}{"user", "alice", "attempt", 2}, expected: `"message":"pairs","user":"alice","attempt":2`},
+		{msg: "typed", args: []interface{}{Int("n", 1), "k", "v"}, expected: `"message":"typed","n":1,"k":"v"`},
+		{msg: "count", args: []interface{}{3}, expected: `"message":"count3"`},
+		{msg: "dangling", args: []interface{}{"key"}, expected: `"message":"danglingkey"`},
+		{msg: "numbers", args: []interface{}{1, 2}, expected: `"message":"numbers1 2"`},
+	}
+	paths := map[string]func(msg string, args ...interface{}){
+		"global": Info,
+		"logger": GetLogger().Info,
+		"v":      V(zapcore.InfoLevel).Info,
+	}
+	for _, tt := range tests {
+		for _, name := range []string{"global", "logger", "v"} {
+			paths[name](tt.msg, tt.args...)
+		}
+	}
+
+	lines := output()
+	if len(lines) != 3*len(tests) {
+		t.Fatalf("expected %d lines, got %q", 3*len(tests), lines)
+	}
+	for i, tt := range tests {
+		var want map[string]interface{}
+		for j, name := range []string{"global", "logger", "v"} {
+			line := lines[3*i+j]
+			if !strings.Contains(line, tt.expected) {
+				t.Errorf("%s %s: expected %s in %s", tt.msg, name, tt.expected, line)
+			}
+			var got map[string]interface{}
+			if err := json.Unmarshal([]byte(line), &got); err != nil {
+				t.Fatal(err)
+			}
+			delete(got, "timestamp")
+			if want == nil {
+				want = got
+			} else if fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Errorf("%s %s: %v differs from %v", tt.msg, name, got, want)
+			}
+		}
+	}
+}
+
+func TestDerivedLoggers(t *testing.T) {
+	opts := NewOptions()
+	opts.DisableCaller = true
+	base, output := newTestLogger(t, opts)
+
+	steps := []struct {
+		name   string
+		derive func(Logger) Logger
+		want   string
+	}{
+		{"WithName", func(l Logger) Logger { return l.WithName("child") }, `"logger":"child`},
+		{"With", func(l Logger) Logger { return l.With("with", 1) }, `"with":1`},
+		{"WithSkip", func(l Logger) Logger { return l.WithSkip(0, "skip", 1) }, `"skip":1`},
+		{"Ctx", func(l Logger) Logger { return l.Ctx(recordingContext()) }, `"trace_id":"01000000000000000000000000000000"`},
+		{"WithTraceID", func(l Logger) Logger { return l.WithTraceID(recordingContext()) }, `"trace_id":"01000000000000000000000000000000"`},
+	}
+	type combination struct {
+		name string
+		want []string
+	}
+	var combinations []combination
+	for _, first := range steps {
+		for _, second := range steps {
+			name := first.name + "+" + second.name
+			child := second.derive(first.derive(base))
+			if l := child.(*logger); l.options != base.options || l.controls != base.controls {
+				t.Errorf("%s: options or controls lost", name)
+			}
+			child.Info(name)
+			child.V(zapcore.InfoLevel).Info(name)
+			combinations = append(combinations, combination{name: name, want: []string{first.want, second.want}})
+		}
+	}
+	lines := output()
+	if len(lines) != 2*len(combinations) {
+		t.Fatalf("expected %d lines, got %d", 2*len(combinations), len(lines))
+	}
+	for i, c := range combinations {
+		for j, path := range []string{"Info", "V"} {
+			line := lines[2*i+j]
+			for _, want := range c.want {
+				// V loggers have no context, so only Info carries the trace id.
+				if path == "V" && strings.Contains(want, "trace_id") {
+					continue
+				}
+				if !strings.Contains(line, want) {
+					t.Errorf("%s %s: expected %s in %s", c.name, path, want, line)
+				}
+			}
+		}
+	}
+}
+
+// callerLine returns the line it is called from.
+func callerLine() int {
+	_, _, line, _ := runtime.Caller(1)
+	return line
+}
+
+func logThroughHelper(l Logger, msg string) {
+	l.WithSkip(1).Infow(msg)
+}
+
+func TestCaller(t *testing.T) {
+	opts := NewOptions()
+	opts.Level = "debug"
+	output := initTestLogger(t, opts)
+	l := GetLogger()
+	ws, closeOut, err := zap.Open(opts.OutputPaths[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeOut()
+	wrapped := NewLogger(zap.New(
+		zapcore.NewCore(zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()), ws, zapcore.DebugLevel),
+		zap.AddCaller(),
+	))
+	ctx := context.WithValue(recordingContext(), KeyRequestID, "id")
+
+	tests := []struct {
+		name string
+		log  func() int
+	}{
+		{"Info", func() int { Info("m"); return callerLine() }},
+		{"Print", func() int { Print(errors.New("m")); return callerLine() }},
+		{"Infow", func() int { Infow("m", "k", "v"); return callerLine() }},
+		{"Infof", func() int { Infof("m %d", 1); return callerLine() }},
+		{"Debug", func() int { Debug("m"); return callerLine() }},
+		{"Warnw", func() int { Warnw("m"); return callerLine() }},
+		{"Errorf", func() int { Errorf("m"); return callerLine() }},
+		{"V", func() int { V(zapcore.InfoLevel).Infow("m"); return callerLine() }},
+		{"With", func() int { With("k", "v").Infow("m"); return callerLine() }},
+		{"WithName", func() int { WithName("n").Info("m"); return callerLine() }},
+		{"Ctx", func() int { Ctx(ctx).Infow("m"); return callerLine() }},
+		{"WithTraceID", func() int { WithTraceID(ctx).Infow("m"); return callerLine() }},
+		{"L", func() int { L(ctx).Infow("m"); return callerLine() }},
+		{"FromContext", func() int { FromContext(l.WithContext(ctx)).Infow("m"); return callerLine() }},
+		{"Desugared", func() int { Desugared().Info("m"); return callerLine() }},
+		{"Logger.Info", func() int { l.Info("m", "k", "v"); return callerLine() }},
+		{"Logger.Debugw", func() int { l.Debugw("m"); return callerLine() }},
+		{"Logger.Log", func() int { l.Log(WarnLevel, "m"); return callerLine() }},
+		{"Logger.Logf", func() int { l.Logf(InfoLevel, "m"); return callerLine() }},
+		{"Logger.Logw", func() int { l.Logw(ErrorLevel, "m"); return callerLine() }},
+		{"Logger.V", func() int { l.V(zapcore.DebugLevel).Infof("m"); return callerLine() }},
+		{"Logger.With.V", func() int { l.With("k", "v").V(zapcore.InfoLevel).Info("m"); return callerLine() }},
+		{"Logger.WithName.With", func() int { l.WithName("n").With("k", "v").Warn("m"); return callerLine() }},
+		{"Logger.Ctx.Desugared", func() int { l.Ctx(ctx).Desugared().Log(InfoLevel, "m"); return callerLine() }},
+		{"Desugared.With", func() int { l.Desugared().With(String("k", "v")).Info("m"); return callerLine() }},
+		{"WithSkip", func() int { logThroughHelper(l, "m"); return callerLine() }},
+		{"NewLogger", func() int { wrapped.Infow("m"); return callerLine() }},
+		{"NewLogger.With", func() int { wrapped.With("k", "v").Info("m"); return callerLine() }},
+	}
+	lines := make([]int, len(tests))
+	for i, tt := range tests {
+		lines[i] = tt.log()
+	}
+
+	entries := output()
+	if len(entries) != len(tests) {
+		t.Fatalf("expected %d entries, got %d", len(tests), len(entries))
+	}
+	for i, tt := range tests {
+		var entry struct {
+			Caller string `json:"caller"`
+		}
+		if err := json.Unmarshal([]byte(entries[i]), &entry); err != nil {
+			t.Fatal(err)
+		}
+		if want := fmt.Sprintf("logger_test.go:%d", lines[i]); !strings.HasSuffix(entry.Caller, "/"+want) {
+			t.Errorf("%s: expected caller %s, got %s", tt.name, want, entry.Caller)
+		}
+	}
+}
+
+func TestInstallGlobals(t *testing.T) {
+	if opts := NewOptions(); opts.RedirectStdLog || opts.RedirectKlog {
+		t.Fatal("the global loggers are redirected by default")
+	}
+	opts := NewOptions()
+	opts.RedirectStdLog = true
+	opts.RedirectKlog = true
+	opts.ReplaceZapGlobals = true
+	// restoring the standard library log sets its output to stderr
+	log.SetOutput(os.Stderr)
+	stdWriter, zapGlobal := log.Writer(), zap.L()
+
+	l, output := newTestLogger(t, opts)
+	if log.Writer() != stdWriter || zap.L() != zapGlobal {
+		t.Fatal("New changed the global loggers")
+	}
+
+	undo := l.InstallGlobals()
+	log.Print("std message")
+	zap.L().Info("zap message")
+	klog.Info("klog message")
+	undo()
+	if log.Writer() != stdWriter || zap.L() != zapGlobal {
+		t.Fatal("global loggers not restored")
+	}
+	klog.Info("klog message after undo")
+	klog.Flush()
+
+	lines := output()
+	matchLines(t, lines, []string{
+		`"message":"std message"`,
+		`"message":"zap message"`,
+		`"level":"INFO",.*"message":"klog message"`,
+	})
+}
+
+func TestStdLogger(t *testing.T) {
+	l, output := newTestLogger(t, NewOptions())
+
+	std := StdLogger(l, WarnLevel)
+	std.Print("first\nsecond")
+	line := callerLine() - 1
+	w := Writer(l, InfoLevel)
+	w.ParsePrefix = true
+	fmt.Fprint(w, "[ERROR] failed\nWARNING: slow\n[custom] kept\npar")
+	fmt.Fprint(w, "tial\r\n\nFATAL: not fatal\nrest")
+	w.Flush()
+
+	lines := output()
+	matchLines(t, lines, []string{
+		`"level":"WARN",.*"message":"first"`,
+		`"level":"WARN",.*"message":"second"`,
+		`"level":"ERROR",.*"message":"failed"`,
+		`"level":"WARN",.*"message":"slow"`,
+		`"level":"INFO",.*"message":"\[custom\] kept"`,
+		`"level":"INFO",.*"message":"partial"`,
+		`"level":"ERROR",.*"message":"not fatal"`,
+		`"level":"INFO",.*"message":"rest"`,
+	})
+	if caller := fmt.Sprintf("/logger_test.go:%d", line); !strings.Contains(lines[0], caller) {
+		t.Errorf("expected caller %s in %s", caller, lines[0])
+	}
+	l, output = newTestLogger(t, NewOptions())
+	std = PrefixStdLogger(l, WarnLevel)
+	std.Print("[ERROR] failed")
+	line = callerLine() - 1
+	std.Print("unprefixed")
+	lines = output()
+	matchLines(t, lines, []string{
+		`"level":"ERROR",.*"message":"failed"`,
+		`"level":"WARN",.*"message":"unprefixed"`,
+	})
+	if caller := fmt.Sprintf("/logger_test.go:%d", line); !strings.Contains(lines[0], caller) {
+		t.Errorf("expected caller %s in %s", caller, lines[0])
+	}
+}
+
+var (
+	registerBlockingSink sync.Once
+	// blockingRelease are the release channels of the blocking sinks by host.
+	blockingRelease = map[string]chan struct{}{}
+)
+
+// blockingSink is a zap sink whose Sync blocks until release is closed.
+type blockingSink struct {
+	release chan struct{}
+}
+
+func (s blockingSink) Write(p []byte) (int, error) { return len(p), nil }
+func (s blockingSink) Sync() error                 { <-s.release; return nil }
+func (s blockingSink) Close() error                { return nil }
+
+func TestShutdown(t *testing.T) {
+	var codes []int
+	opts := NewOptions()
+	opts.Dedup.Window = time.Hour
+	opts.ExitFunc = func(code int) { codes = append(codes, code) }
+	l, _ := newTestLogger(t, opts)
+
+	l.Fatal("fatal message")
+	for i := 0; i < 3; i++ {
+		l.Infow("repeated message")
+	}
+	signals := make(chan os.Signal, 1)
+	signals <- syscall.SIGTERM
+	l.handleSignals(signals, nil, time.Second)
+
+	if fmt.Sprint(codes) != "[1 143]" {
+		t.Errorf("unexpected exit codes %v", codes)
+	}
+	// the logger is shut down, its output is read as is
+	out := strings.Join(readLines(t, opts.OutputPaths[0]), "\n")
+	for _, want := range []string{`"message":"fatal message"`, `"message":"shutting down","signal":"terminated"`, `"repeated":2`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %s in %s", want, out)
+		}
+	}
+
+	release := make(chan struct{})
+	defer close(release)
+	var err error
+	registerBlockingSink.Do(func() {
+		err = zap.RegisterSink("blocking", func(u *url.URL) (zap.Sink, error) {
+			return blockingSink{release: blockingRelease[u.Host]}, nil
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	blockingRelease[t.Name()] = release
+	opts = NewOptions()
+	opts.OutputPaths = []string{"blocking://" + t.Name()}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := New(opts).Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the deadline to end the shutdown, got %v", err)
+	}
+}
+
+func panicking(l Logger, opts *RecoverOptions) {
+	defer RecoverAndLog(l, opts)
+	panic("boom")
+}
+
+func TestRecover(t *testing.T) {
+	l, output := newTestLogger(t, NewOptions())
+
+	panicking(l, nil)
+	var reported interface{}
+	func() {
+		defer func() { reported = recover() }()
+		panicking(l, &RecoverOptions{Level: WarnLevel, Message: "worker crashed", Repanic: true})
+	}()
+	if reported != "boom" {
+		t.Errorf("expected the panic to be repanicked, got %v", reported)
+	}
+
+	done := make(chan interface{})
+	// the middlewares store a logger with the request id in the context
+	ctx := context.WithValue(recordingContext(), KeyRequestID, "req-1")
+	ctx = l.With(KeyRequestID, "req-1").(*logger).WithContext(ctx)
+	(&RecoverOptions{OnPanic: func(v interface{}) { done <- v }}).Go(ctx, func(context.Context) {
+		panic(errors.New("goroutine failed"))
+	})
+	if v := <-done; fmt.Sprint(v) != "goroutine failed" {
+		t.Errorf("unexpected value reported %v", v)
+	}
+
+	lines := output()
+	matchLines(t, lines, []string{
+		`"level":"ERROR",.*"message":"recovered from panic","panic":"boom","stack":".*logger.panicking`,
+		`"level":"WARN",.*"message":"worker crashed","panic":"boom"`,
+		`"level":"ERROR",.*"X-Request-ID":"req-1","panic":"goroutine failed","stack":".*TestRecover.*"trace_id":"01000000000000000000000000000000"`,
+	})
+	if n := strings.Count(lines[2], KeyRequestID); n != 1 {
+		t.Errorf("expected the request id once, got %d times in %s", n, lines[2])
+	}
+}
+
+func readSyslog(t *testing.T, conn net.PacketConn) string {
+	t.Helper()
+	buf := make([]byte, 4096)
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(buf[:n])
+}
+
+func TestSyslog(t *testing.T) {
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer udp.Close()
+	opts := NewOptions()
+	opts.Format = jsonFormat
+	opts.OutputPaths = []string{"syslog://" + udp.LocalAddr().String() + "?facility=local0&tag=app"}
+	l := New(opts)
+	defer l.Shutdown(context.Background())
+
+	l.With("component", "db").Warnw("slow query", "query", `select "x"]`, "rows", 3)
+	msg := readSyslog(t, udp)
+	header := regexp.MustCompile(`^<132>1 \S+ \S+ app \d+ - \[fields@32473 component="db" query="select \\"x\\"\\\]" rows="3"\] \{.*"message":"slow query"`)
+	if !header.MatchString(msg) {
+		t.Errorf("unexpected RFC 5424 message %q", msg)
+	}
+
+	path := filepath.Join(t.TempDir(), "log")
+	unix, err := net.ListenPacket("unixgram", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts = NewOptions()
+	opts.OutputPaths = []string{"unixgram://" + path + "?tag=app"}
+	opts.ErrorOutputPaths = []string{filepath.Join(t.TempDir(), "error.log")}
+	l = New(opts)
+	defer l.Shutdown(context.Background())
+
+	l.Errorw("first")
+	if msg := readSyslog(t, unix); !regexp.MustCompile(`^<11>\w{3} [ \d]\d \d\d:\d\d:\d\d app\[\d+\]: .*first`).MatchString(msg) {
+		t.Errorf("unexpected RFC 3164 message %q", msg)
+	}
+
+	// the daemon restarts, the entries are dropped until the backoff is over
+	unix.Close()
+	os.Remove(path)
+	l.Errorw("lost")
+	l.Errorw("dropped")
+	if errOut := strings.Join(readLines(t, opts.ErrorOutputPaths[0]), "\n"); !strings.Contains(errOut, errSyslogDisconnected.Error()) {
+		t.Errorf("expected the entry to be dropped while disconnected, got %q", errOut)
+	}
+	if unix, err = net.ListenPacket("unixgram", path); err != nil {
+		t.Fatal(err)
+	}
+	defer unix.Close()
+	time.Sleep(syslogMinBackoff)
+	l.Errorw("after restart")
+	if msg := readSyslog(t, unix); !strings.Contains(msg, "after restart") {
+		t.Errorf("expected the sink to reconnect, got %q", msg)
+	}
+
+	opts = NewOptions()
+	opts.OutputPaths = []string{"syslog://host?facility=nope"}
+	if errs := opts.Validate(); len(errs) != 1 {
+		t.Errorf("expected an invalid facility to be rejected, got %v", errs)
+	}
+}
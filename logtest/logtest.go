@@ -0,0 +1,33 @@
+// Package logtest provides a logger writing to a temporary file, for the
+// tests of the packages built on top of the logger.
+package logtest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/costa92/logger"
+)
+
+// New returns a logger built from opts, logger.NewOptions when nil, with its
+// output paths replaced by a file removed at the end of the test. output
+// flushes the logger and returns everything written to the file.
+func New(t testing.TB, opts *logger.Options) (l logger.Logger, output func() string) {
+	t.Helper()
+	if opts == nil {
+		opts = logger.NewOptions()
+	}
+	path := filepath.Join(t.TempDir(), "out.log")
+	opts.OutputPaths = []string{path}
+	l = logger.New(opts)
+	return l, func() string {
+		t.Helper()
+		l.Flush()
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+}
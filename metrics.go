@@ -0,0 +1,81 @@
+package logger
+
+import (
+	"go.uber.org/zap/zapcore"
+)
+
+// MetricsRecorder counts the entries written by a logger, the entries dropped
+// by sampling and the writes of every sink. Pass one to New through
+// Options.Metrics, the promlog package implements it with Prometheus
+// counters.
+type MetricsRecorder interface {
+	// Entry is called for every entry written.
+	Entry(level Level, loggerName string)
+	// Sampled is called for every entry dropped by sampling.
+	Sampled(level Level)
+	// Sink returns the metrics of the sink at path, it is called once per
+	// sink when the logger is built.
+	Sink(path string) SinkMetrics
+}
+
+// SinkMetrics counts the writes and syncs of one sink.
+type SinkMetrics interface {
+	// Written is called after every write with the bytes written and the
+	// error of the write.
+	Written(n int, err error)
+	// Synced is called after every sync with its error.
+	Synced(err error)
+}
+
+// metricsCore wraps core to count the entries written through it.
+func metricsCore(core zapcore.Core, m MetricsRecorder) zapcore.Core {
+	return &countingCore{Core: core, metrics: m}
+}
+
+type countingCore struct {
+	zapcore.Core
+	metrics MetricsRecorder
+}
+
+func (c *countingCore) With(fields []zapcore.Field) zapcore.Core {
+	return &countingCore{Core: c.Core.With(fields), metrics: c.metrics}
+}
+
+func (c *countingCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *countingCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	c.metrics.Entry(Level(ent.Level), ent.LoggerName)
+	return c.Core.Write(ent, fields)
+}
+
+// samplingHook counts the entries dropped by sampling.
+func samplingHook(m MetricsRecorder) func(zapcore.Entry, zapcore.SamplingDecision) {
+	return func(ent zapcore.Entry, dec zapcore.SamplingDecision) {
+		if dec&zapcore.LogDropped != 0 {
+			m.Sampled(Level(ent.Level))
+		}
+	}
+}
+
+// countingWriteSyncer counts the bytes and errors of the sink it wraps.
+type countingWriteSyncer struct {
+	zapcore.WriteSyncer
+	metrics SinkMetrics
+}
+
+func (w *countingWriteSyncer) Write(p []byte) (int, error) {
+	n, err := w.WriteSyncer.Write(p)
+	w.metrics.Written(n, err)
+	return n, err
+}
+
+func (w *countingWriteSyncer) Sync() error {
+	err := w.WriteSyncer.Sync()
+	w.metrics.Synced(err)
+	return err
+}
@@ -3,6 +3,7 @@ package logger
 import (
 	"encoding/json"
 	"fmt"
+	"os"
 	"strings"
 
 	"github.com/spf13/pflag"
@@ -13,8 +14,10 @@ const (
 	flagLevel             = "log.level"
 	flagFormat            = "log.format"
 	flagEnableColor       = "log.enable-color"
+	flagColor             = "log.color"
 	flagEnableCaller      = "log.enable-caller"
 	flagDisableStacktrace = "log.disable-stacktrace"
+	flagDisableSampling   = "log.disable-sampling"
 	flagOutputPaths       = "log.output-paths"
 	flagErrorOutputPaths  = "log.error-output-paths"
 	flagDisableCaller     = "log.disable-caller"
@@ -22,20 +25,57 @@ const (
 	jsonFormat            = "json"
 	flagDevelopment       = "log.development"
 	flagName              = "log.name"
+	flagSchema            = "log.schema"
+	flagGCPProject        = "log.gcp-project"
+	flagTimeLayout        = "log.time-layout"
+	flagTimeUTC           = "log.time-utc"
+	flagDurationUnit      = "log.duration-unit"
+	flagLevelCase         = "log.level-case"
+	flagCallerEncoder     = "log.caller-encoder"
+	flagErrorEncoding     = "log.error-encoding"
+	flagDedupWindow       = "log.dedup-window"
+	flagDedupMaxEntries   = "log.dedup-max-entries"
+	flagRateLimitBy       = "log.rate-limit-by"
+	flagRateLimitField    = "log.rate-limit-field"
+	flagRateLimitRate     = "log.rate-limit"
+	flagRateLimitBurst    = "log.rate-limit-burst"
+	flagRecorderSize      = "log.flight-recorder-size"
+	flagRecorderLevel     = "log.flight-recorder-level"
+	flagRecorderScope     = "log.flight-recorder-scope"
+	flagRedirectStdLog    = "log.redirect-std-log"
+	flagRedirectKlog      = "log.redirect-klog"
+	flagReplaceZapGlobals = "log.replace-zap-globals"
 )
 
 type Options struct {
-	Level             string                 `json:"level" yaml:"level" mapstructure:"level"`
-	Format            string                 `json:"format" yaml:"format" mapstructure:"format"`
-	EnableColor       bool                   `json:"enable-color" yaml:"enable-color" mapstructure:"enable-color"`
-	EnableCaller      bool                   `json:"enable-caller" yaml:"enable-caller" mapstructure:"enable-caller"`
-	OutputPaths       []string               `json:"output-paths" yaml:"output-paths" mapstructure:"output-paths"`
-	ErrorOutputPaths  []string               `json:"error-output-paths" yaml:"error-output-paths" mapstructure:"error-output-paths"`
-	Development       bool                   `json:"development"    yaml:"development"    mapstructure:"development"`
-	Name              string                 `json:"name" yaml:"name"  mapstructure:"name"`
-	DisableCaller     bool                   `json:"disable-caller"  yaml:"disable-caller"   mapstructure:"disable-caller"`
-	DisableStacktrace bool                   `json:"disable-stacktrace" yaml:"disable-stacktrace" mapstructure:"disable-stacktrace"`
-	FieldPair         map[string]interface{} `json:"field-pair" yaml:"field-pair" mapstructure:"field-pair"`
+	Level             string                       `json:"level" yaml:"level" mapstructure:"level"`
+	Format            string                       `json:"format" yaml:"format" mapstructure:"format"`
+	EnableColor       bool                         `json:"enable-color" yaml:"enable-color" mapstructure:"enable-color"`
+	Color             string                       `json:"color" yaml:"color" mapstructure:"color"`
+	EnableCaller      bool                         `json:"enable-caller" yaml:"enable-caller" mapstructure:"enable-caller"`
+	OutputPaths       []string                     `json:"output-paths" yaml:"output-paths" mapstructure:"output-paths"`
+	ErrorOutputPaths  []string                     `json:"error-output-paths" yaml:"error-output-paths" mapstructure:"error-output-paths"`
+	Development       bool                         `json:"development"    yaml:"development"    mapstructure:"development"`
+	Name              string                       `json:"name" yaml:"name"  mapstructure:"name"`
+	DisableCaller     bool                         `json:"disable-caller"  yaml:"disable-caller"   mapstructure:"disable-caller"`
+	DisableStacktrace bool                         `json:"disable-stacktrace" yaml:"disable-stacktrace" mapstructure:"disable-stacktrace"`
+	DisableSampling   bool                         `json:"disable-sampling" yaml:"disable-sampling" mapstructure:"disable-sampling"`
+	FieldPair         map[string]interface{}       `json:"field-pair" yaml:"field-pair" mapstructure:"field-pair"`
+	Schema            Schema                       `json:"schema" yaml:"schema" mapstructure:"schema"`
+	GCPProject        string                       `json:"gcp-project" yaml:"gcp-project" mapstructure:"gcp-project"`
+	Encoder           EncoderOptions               `json:"encoder" yaml:"encoder" mapstructure:"encoder"`
+	Sinks             []SinkOptions                `json:"sinks" yaml:"sinks" mapstructure:"sinks"`
+	ErrorEncoding     string                       `json:"error-encoding" yaml:"error-encoding" mapstructure:"error-encoding"`
+	Dedup             DedupOptions                 `json:"dedup" yaml:"dedup" mapstructure:"dedup"`
+	RateLimit         RateLimitOptions             `json:"rate-limit" yaml:"rate-limit" mapstructure:"rate-limit"`
+	FlightRecorder    FlightRecorderOptions        `json:"flight-recorder" yaml:"flight-recorder" mapstructure:"flight-recorder"`
+	RedirectStdLog    bool                         `json:"redirect-std-log" yaml:"redirect-std-log" mapstructure:"redirect-std-log"`
+	RedirectKlog      bool                         `json:"redirect-klog" yaml:"redirect-klog" mapstructure:"redirect-klog"`
+	ReplaceZapGlobals bool                         `json:"replace-zap-globals" yaml:"replace-zap-globals" mapstructure:"replace-zap-globals"`
+	Metrics           MetricsRecorder              `json:"-" yaml:"-" mapstructure:"-"`
+	Hooks             []func(Entry, []Field) error `json:"-" yaml:"-" mapstructure:"-"`
+	EntryHooks        []EntryHook                  `json:"-" yaml:"-" mapstructure:"-"`
+	ExitFunc          func(code int)               `json:"-" yaml:"-" mapstructure:"-"`
 }
 
 func NewOptions() *Options {
@@ -46,6 +86,9 @@ func NewOptions() *Options {
 		EnableCaller:     false,
 		OutputPaths:      []string{"stdout"},
 		ErrorOutputPaths: []string{"stderr"},
+		RedirectStdLog:   false,
+		RedirectKlog:     false,
+		GCPProject:       os.Getenv("GOOGLE_CLOUD_PROJECT"),
 	}
 }
 
@@ -57,12 +100,51 @@ func (o *Options) Validate() []error {
 	}
 
 	format := strings.ToLower(o.Format)
-	if format != consoleFormat && format != jsonFormat {
+	if format != consoleFormat && format != jsonFormat && format != prettyFormat {
 		errs = append(errs, fmt.Errorf("not a valid log format: %q", o.Format))
 	}
+
+	switch o.Color {
+	case "", colorAuto, colorAlways, colorNever:
+	default:
+		errs = append(errs, fmt.Errorf("not a valid color mode: %q", o.Color))
+	}
+
+	switch o.ErrorEncoding {
+	case "", errorEncodingDefault, errorEncodingRich:
+	default:
+		errs = append(errs, fmt.Errorf("not a valid error encoding: %q", o.ErrorEncoding))
+	}
+
+	if _, err := o.Schema.preset(); err != nil {
+		errs = append(errs, err)
+	}
+	errs = append(errs, o.Encoder.Validate()...)
+	errs = append(errs, o.RateLimit.Validate()...)
+	errs = append(errs, o.FlightRecorder.Validate()...)
+	for _, path := range o.OutputPaths {
+		if _, _, err := syslogConfigOf(path); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for i := range o.Sinks {
+		errs = append(errs, o.Sinks[i].Validate()...)
+	}
 	return errs
 }
 
+// colorMode returns the effective color mode, EnableColor alone means auto
+// so that files never receive color escapes.
+func (o *Options) colorMode() string {
+	if o.Color != "" {
+		return o.Color
+	}
+	if o.EnableColor {
+		return colorAuto
+	}
+	return colorNever
+}
+
 func (o *Options) String() string {
 	data, _ := json.Marshal(o)
 	return string(data)
@@ -73,8 +155,13 @@ func (o *Options) AddFlags(fs *pflag.FlagSet) {
 	fs.BoolVar(&o.DisableCaller, flagDisableCaller, o.DisableCaller, "Disable output of caller information in the log.")
 	fs.BoolVar(&o.DisableStacktrace, flagDisableStacktrace,
 		o.DisableStacktrace, "Disable the log to record a stack trace for all messages at or above panic level.")
-	fs.StringVar(&o.Format, flagFormat, o.Format, "Log output `FORMAT`, support plain or json format.")
-	fs.BoolVar(&o.EnableColor, flagEnableColor, o.EnableColor, "Enable output ansi colors in plain format logs.")
+	fs.BoolVar(&o.DisableSampling, flagDisableSampling, o.DisableSampling,
+		"Disable sampling, which otherwise keeps the first 100 identical entries per second and every 100th after that.")
+	fs.StringVar(&o.Format, flagFormat, o.Format, "Log output `FORMAT`, support console, json or pretty format.")
+	fs.BoolVar(&o.EnableColor, flagEnableColor, o.EnableColor, "Enable output ansi colors in console and pretty format logs.")
+	fs.StringVar(&o.Color, flagColor, o.Color,
+		"Color `MODE` of console and pretty format logs, support auto, always or never. "+
+			"auto only colors outputs attached to a terminal.")
 	fs.StringSliceVar(&o.OutputPaths, flagOutputPaths, o.OutputPaths, "Output paths of log.")
 	fs.StringSliceVar(&o.ErrorOutputPaths, flagErrorOutputPaths, o.ErrorOutputPaths, "Error output paths of log.")
 	fs.BoolVar(
@@ -85,4 +172,42 @@ func (o *Options) AddFlags(fs *pflag.FlagSet) {
 			"the behavior of DPanicLevel and takes stacktraces more liberally.",
 	)
 	fs.StringVar(&o.Name, flagName, o.Name, "The name of the logger.")
+	fs.StringVar((*string)(&o.Schema), flagSchema, string(o.Schema),
+		"Structured log `SCHEMA` of the output keys, support ecs, gelf or gcp. Empty keeps the default layout.")
+	fs.StringVar(&o.GCPProject, flagGCPProject, o.GCPProject,
+		"Google Cloud `PROJECT` of the traces correlated by the gcp schema, $GOOGLE_CLOUD_PROJECT by default. Without one the bare trace id is written.")
+	fs.StringVar(&o.ErrorEncoding, flagErrorEncoding, o.ErrorEncoding,
+		"`ENCODING` of error fields, support default or rich. rich adds the type, "+
+			"the chain of wrapped errors and the stack trace.")
+	fs.DurationVar(&o.Dedup.Window, flagDedupWindow, o.Dedup.Window,
+		"Collapse identical log entries within this `WINDOW` into one entry and a summary. 0 disables it.")
+	fs.IntVar(&o.Dedup.MaxEntries, flagDedupMaxEntries, o.Dedup.MaxEntries,
+		"Maximum `NUMBER` of distinct log entries remembered for deduplication.")
+	fs.StringVar(&o.RateLimit.By, flagRateLimitBy, o.RateLimit.By,
+		"Rate limit log entries per `KEY`, support logger, level or field.")
+	fs.StringVar(&o.RateLimit.Field, flagRateLimitField, o.RateLimit.Field,
+		"`FIELD` keying the rate limit when rate limiting by field, e.g. tenant_id.")
+	fs.Float64Var(&o.RateLimit.Rate, flagRateLimitRate, o.RateLimit.Rate,
+		"Log entries per second allowed for every rate limit key. 0 disables the limit.")
+	fs.IntVar(&o.RateLimit.Burst, flagRateLimitBurst, o.RateLimit.Burst,
+		"Log entries a rate limit key may log at once.")
+	fs.IntVar(&o.FlightRecorder.Size, flagRecorderSize, o.FlightRecorder.Size,
+		"Keep the last `NUMBER` of entries below the log level per request and write them when an error is logged. 0 disables it.")
+	fs.StringVar(&o.FlightRecorder.Level, flagRecorderLevel, o.FlightRecorder.Level,
+		"Lowest `LEVEL` kept by the flight recorder, debug by default.")
+	fs.StringVar(&o.FlightRecorder.Scope, flagRecorderScope, o.FlightRecorder.Scope,
+		"`SCOPE` of the entries written on error, support request or global.")
+	fs.BoolVar(&o.RedirectStdLog, flagRedirectStdLog, o.RedirectStdLog,
+		"Redirect the standard library log package to the global logger when it is initialized.")
+	fs.BoolVar(&o.RedirectKlog, flagRedirectKlog, o.RedirectKlog,
+		"Redirect klog to the global logger when it is initialized.")
+	fs.BoolVar(&o.ReplaceZapGlobals, flagReplaceZapGlobals, o.ReplaceZapGlobals,
+		"Replace zap.L and zap.S with the global logger when it is initialized.")
+	fs.StringVar(&o.Encoder.TimeLayout, flagTimeLayout, o.Encoder.TimeLayout,
+		"Time `LAYOUT` of the log timestamp, a Go layout or one of epoch, epoch-millis, epoch-nanos, iso8601, rfc3339, rfc3339nano.")
+	fs.BoolVar(&o.Encoder.UTC, flagTimeUTC, o.Encoder.UTC, "Encode the log timestamp in UTC.")
+	fs.StringVar(&o.Encoder.DurationUnit, flagDurationUnit, o.Encoder.DurationUnit,
+		"`UNIT` of duration fields, support ms, s, ns or string.")
+	fs.StringVar(&o.Encoder.LevelCase, flagLevelCase, o.Encoder.LevelCase, "`CASE` of the log level, support capital or lower.")
+	fs.StringVar(&o.Encoder.Caller, flagCallerEncoder, o.Encoder.Caller, "Caller `ENCODER`, support short or full.")
 }
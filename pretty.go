@@ -0,0 +1,376 @@
+package logger
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// prettyFormat renders entries for humans reading a terminal during local
+// development. It is not meant to be parsed.
+const prettyFormat = "pretty"
+
+const (
+	ansiReset   = "\x1b[0m"
+	ansiBold    = "\x1b[1m"
+	ansiRed     = "\x1b[31m"
+	ansiGreen   = "\x1b[32m"
+	ansiYellow  = "\x1b[33m"
+	ansiBlue    = "\x1b[34m"
+	ansiMagenta = "\x1b[35m"
+	ansiCyan    = "\x1b[36m"
+	ansiGray    = "\x1b[90m"
+)
+
+const (
+	prettyTimeLayout  = "15:04:05.000"
+	prettyLevelWidth  = 6
+	prettyNameWidth   = 14
+	prettyCallerWidth = 24
+	prettyIndent      = "    "
+)
+
+var prettyPool = buffer.NewPool()
+
+type prettyKind int
+
+const (
+	prettyString prettyKind = iota
+	prettyNumber
+	prettyBool
+	prettyTime
+	prettyError
+	prettyJSON
+)
+
+type prettyField struct {
+	key   string
+	value interface{}
+	kind  prettyKind
+}
+
+// prettyEncoder is a zapcore.Encoder writing aligned, colored lines with
+// key=value fields, indented JSON for composite values and highlighted
+// stack frames of the main module.
+type prettyEncoder struct {
+	cfg       zapcore.EncoderConfig
+	color     bool
+	module    string
+	namespace string
+	fields    []prettyField
+}
+
+var _ zapcore.Encoder = (*prettyEncoder)(nil)
+
+func newPrettyEncoder(cfg zapcore.EncoderConfig, color bool) *prettyEncoder {
+	return &prettyEncoder{
+		cfg:    cfg,
+		color:  color,
+		module: mainModule(),
+	}
+}
+
+// mainModule returns the module path of the running binary, frames under it
+// are the ones a developer is interested in.
+func mainModule() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	return info.Main.Path
+}
+
+func (e *prettyEncoder) add(key string, value interface{}, kind prettyKind) {
+	e.fields = append(e.fields, prettyField{key: e.namespace + key, value: value, kind: kind})
+}
+
+func (e *prettyEncoder) AddArray(key string, v zapcore.ArrayMarshaler) error {
+	m := zapcore.NewMapObjectEncoder()
+	err := m.AddArray(key, v)
+	e.add(key, m.Fields[key], prettyJSON)
+	return err
+}
+
+func (e *prettyEncoder) AddObject(key string, v zapcore.ObjectMarshaler) error {
+	m := zapcore.NewMapObjectEncoder()
+	err := m.AddObject(key, v)
+	e.add(key, m.Fields[key], prettyJSON)
+	return err
+}
+
+func (e *prettyEncoder) AddBinary(key string, v []byte) {
+	e.add(key, base64.StdEncoding.EncodeToString(v), prettyString)
+}
+
+func (e *prettyEncoder) AddByteString(key string, v []byte) {
+	e.add(key, string(v), prettyString)
+}
+
+func (e *prettyEncoder) AddBool(key string, v bool) {
+	e.add(key, strconv.FormatBool(v), prettyBool)
+}
+
+func (e *prettyEncoder) AddComplex128(key string, v complex128) {
+	e.add(key, strconv.FormatComplex(v, 'g', -1, 128), prettyNumber)
+}
+
+func (e *prettyEncoder) AddComplex64(key string, v complex64) {
+	e.add(key, strconv.FormatComplex(complex128(v), 'g', -1, 64), prettyNumber)
+}
+
+func (e *prettyEncoder) AddDuration(key string, v time.Duration) {
+	e.add(key, v.String(), prettyTime)
+}
+
+func (e *prettyEncoder) AddFloat64(key string, v float64) {
+	e.add(key, strconv.FormatFloat(v, 'g', -1, 64), prettyNumber)
+}
+
+func (e *prettyEncoder) AddFloat32(key string, v float32) {
+	e.add(key, strconv.FormatFloat(float64(v), 'g', -1, 32), prettyNumber)
+}
+
+func (e *prettyEncoder) AddInt(key string, v int)     { e.AddInt64(key, int64(v)) }
+func (e *prettyEncoder) AddInt32(key string, v int32) { e.AddInt64(key, int64(v)) }
+func (e *prettyEncoder) AddInt16(key string, v int16) { e.AddInt64(key, int64(v)) }
+func (e *prettyEncoder) AddInt8(key string, v int8)   { e.AddInt64(key, int64(v)) }
+
+func (e *prettyEncoder) AddInt64(key string, v int64) {
+	e.add(key, strconv.FormatInt(v, 10), prettyNumber)
+}
+
+func (e *prettyEncoder) AddString(key, v string) {
+	e.add(key, v, prettyString)
+}
+
+func (e *prettyEncoder) AddTime(key string, v time.Time) {
+	e.add(key, v.Format("2006-01-02 15:04:05.000"), prettyTime)
+}
+
+func (e *prettyEncoder) AddUint(key string, v uint)       { e.AddUint64(key, uint64(v)) }
+func (e *prettyEncoder) AddUint32(key string, v uint32)   { e.AddUint64(key, uint64(v)) }
+func (e *prettyEncoder) AddUint16(key string, v uint16)   { e.AddUint64(key, uint64(v)) }
+func (e *prettyEncoder) AddUint8(key string, v uint8)     { e.AddUint64(key, uint64(v)) }
+func (e *prettyEncoder) AddUintptr(key string, v uintptr) { e.AddUint64(key, uint64(v)) }
+
+func (e *prettyEncoder) AddUint64(key string, v uint64) {
+	e.add(key, strconv.FormatUint(v, 10), prettyNumber)
+}
+
+func (e *prettyEncoder) AddReflected(key string, v interface{}) error {
+	e.add(key, v, prettyJSON)
+	return nil
+}
+
+func (e *prettyEncoder) OpenNamespace(key string) {
+	e.namespace += key + "."
+}
+
+func (e *prettyEncoder) Clone() zapcore.Encoder {
+	return e.clone()
+}
+
+func (e *prettyEncoder) clone() *prettyEncoder {
+	cloned := *e
+	cloned.fields = make([]prettyField, len(e.fields), len(e.fields)+8)
+	copy(cloned.fields, e.fields)
+	return &cloned
+}
+
+func (e *prettyEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	enc := e.clone()
+	for _, f := range fields {
+		if f.Type == zapcore.ErrorType {
+			enc.addError(f)
+			continue
+		}
+		f.AddTo(enc)
+	}
+
+	buf := prettyPool.Get()
+	if e.cfg.TimeKey != "" && !ent.Time.IsZero() {
+		e.write(buf, ansiGray, ent.Time.Format(prettyTimeLayout))
+		buf.AppendByte(' ')
+	}
+	if e.cfg.LevelKey != "" {
+		e.write(buf, levelColor(ent.Level), pad(ent.Level.CapitalString(), prettyLevelWidth))
+	}
+	if e.cfg.NameKey != "" && ent.LoggerName != "" {
+		e.write(buf, ansiCyan, pad(ent.LoggerName, prettyNameWidth))
+	}
+	if e.cfg.CallerKey != "" && ent.Caller.Defined {
+		e.write(buf, ansiGray, pad(ent.Caller.TrimmedPath(), prettyCallerWidth))
+	}
+	if e.cfg.MessageKey != "" {
+		e.write(buf, ansiBold, ent.Message)
+	}
+
+	var blocks []prettyField
+	for _, f := range enc.fields {
+		text, multiline := e.format(f)
+		if multiline {
+			blocks = append(blocks, prettyField{key: f.key, value: text, kind: f.kind})
+			continue
+		}
+		buf.AppendByte(' ')
+		e.write(buf, ansiGray, f.key+"=")
+		e.write(buf, kindColor(f.kind), text)
+	}
+	buf.AppendString(e.lineEnding())
+
+	for _, b := range blocks {
+		buf.AppendString(prettyIndent)
+		e.write(buf, ansiGray, b.key+"=")
+		buf.AppendString(e.lineEnding())
+		for _, line := range strings.Split(b.value.(string), "\n") {
+			buf.AppendString(prettyIndent + prettyIndent)
+			e.write(buf, kindColor(b.kind), line)
+			buf.AppendString(e.lineEnding())
+		}
+	}
+	if e.cfg.StacktraceKey != "" && ent.Stack != "" {
+		e.writeStack(buf, ent.Stack)
+	}
+	return buf, nil
+}
+
+// addError keeps errors apart from plain strings so they can be colored, and
+// keeps the verbose form of errors carrying a stack trace.
+func (e *prettyEncoder) addError(f zapcore.Field) {
+	err, ok := f.Interface.(error)
+	if !ok || err == nil {
+		f.AddTo(e)
+		return
+	}
+	e.add(f.Key, err.Error(), prettyError)
+	if _, ok := err.(fmt.Formatter); ok {
+		if verbose := fmt.Sprintf("%+v", err); verbose != err.Error() {
+			e.add(f.Key+"Verbose", verbose, prettyError)
+		}
+	}
+}
+
+// format returns the text of a field and whether it spans several lines.
+func (e *prettyEncoder) format(f prettyField) (string, bool) {
+	if f.kind != prettyJSON {
+		s := f.value.(string)
+		if strings.Contains(s, "\n") {
+			return s, true
+		}
+		if f.kind == prettyString || f.kind == prettyError {
+			return quoteIfNeeded(s), false
+		}
+		return s, false
+	}
+
+	compact, err := json.Marshal(f.value)
+	if err != nil {
+		return fmt.Sprintf("%+v", f.value), false
+	}
+	if len(compact) <= 2 || (compact[0] != '{' && compact[0] != '[') {
+		return string(compact), false
+	}
+	indented, err := json.MarshalIndent(f.value, "", "  ")
+	if err != nil {
+		return string(compact), false
+	}
+	return string(indented), true
+}
+
+// writeStack writes the function and file lines of every frame indented, the
+// frames belonging to the main module are highlighted.
+func (e *prettyEncoder) writeStack(buf *buffer.Buffer, stack string) {
+	lines := strings.Split(strings.TrimRight(stack, "\n"), "\n")
+	for i := 0; i < len(lines); i += 2 {
+		color := ansiGray
+		if e.ownFrame(lines[i]) {
+			color = ansiYellow + ansiBold
+		}
+		buf.AppendString(prettyIndent)
+		e.write(buf, color, lines[i])
+		buf.AppendString(e.lineEnding())
+		if i+1 < len(lines) {
+			buf.AppendString(prettyIndent + "  ")
+			e.write(buf, color, strings.TrimSpace(lines[i+1]))
+			buf.AppendString(e.lineEnding())
+		}
+	}
+}
+
+// ownFrame reports whether the function of a stack frame belongs to the main
+// package or module of the binary.
+func (e *prettyEncoder) ownFrame(function string) bool {
+	if strings.HasPrefix(function, "main.") {
+		return true
+	}
+	return e.module != "" && strings.HasPrefix(function, e.module)
+}
+
+func (e *prettyEncoder) write(buf *buffer.Buffer, color, text string) {
+	if !e.color || color == "" {
+		buf.AppendString(text)
+		return
+	}
+	buf.AppendString(color)
+	buf.AppendString(text)
+	buf.AppendString(ansiReset)
+}
+
+func (e *prettyEncoder) lineEnding() string {
+	if e.cfg.LineEnding != "" {
+		return e.cfg.LineEnding
+	}
+	return zapcore.DefaultLineEnding
+}
+
+func levelColor(l zapcore.Level) string {
+	switch l {
+	case zapcore.DebugLevel:
+		return ansiMagenta
+	case zapcore.InfoLevel:
+		return ansiBlue
+	case zapcore.WarnLevel:
+		return ansiYellow
+	default:
+		return ansiRed
+	}
+}
+
+func kindColor(kind prettyKind) string {
+	switch kind {
+	case prettyString:
+		return ansiGreen
+	case prettyNumber:
+		return ansiBlue
+	case prettyBool:
+		return ansiYellow
+	case prettyTime:
+		return ansiMagenta
+	case prettyError:
+		return ansiRed
+	default:
+		return ""
+	}
+}
+
+// pad right-pads s with spaces to width, always leaving one space.
+func pad(s string, width int) string {
+	if len(s) >= width {
+		return s + " "
+	}
+	return s + strings.Repeat(" ", width-len(s))
+}
+
+func quoteIfNeeded(s string) string {
+	if s == "" || strings.ContainsAny(s, " =\"\t") {
+		return strconv.Quote(s)
+	}
+	return s
+}
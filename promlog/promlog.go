@@ -0,0 +1,109 @@
+// Package promlog counts the entries and the sink writes of a logger with
+// Prometheus counters. Register a Metrics with your own registry and pass it
+// to logger.New through Options.Metrics.
+package promlog
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/costa92/logger"
+)
+
+// Metrics counts the entries written by a logger, the bytes written to every
+// sink, the entries dropped by sampling and the write and sync errors of the
+// sinks.
+type Metrics struct {
+	entries *prometheus.CounterVec
+	bytes   *prometheus.CounterVec
+	sampled *prometheus.CounterVec
+	errors  *prometheus.CounterVec
+}
+
+var (
+	_ prometheus.Collector   = (*Metrics)(nil)
+	_ logger.MetricsRecorder = (*Metrics)(nil)
+)
+
+// New creates the log metrics with the given metric namespace.
+func New(namespace string) *Metrics {
+	return &Metrics{
+		entries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "log",
+			Name:      "entries_total",
+			Help:      "Number of log entries written, by level and logger name.",
+		}, []string{"level", "logger"}),
+		bytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "log",
+			Name:      "sink_bytes_total",
+			Help:      "Number of bytes written to a log sink.",
+		}, []string{"sink"}),
+		sampled: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "log",
+			Name:      "sampled_entries_total",
+			Help:      "Number of log entries dropped by sampling, by level.",
+		}, []string{"level"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "log",
+			Name:      "sink_errors_total",
+			Help:      "Number of failed writes and syncs of a log sink.",
+		}, []string{"sink", "op"}),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	m.entries.Describe(ch)
+	m.bytes.Describe(ch)
+	m.sampled.Describe(ch)
+	m.errors.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	m.entries.Collect(ch)
+	m.bytes.Collect(ch)
+	m.sampled.Collect(ch)
+	m.errors.Collect(ch)
+}
+
+// Entry implements logger.MetricsRecorder.
+func (m *Metrics) Entry(level logger.Level, loggerName string) {
+	m.entries.WithLabelValues(level.String(), loggerName).Inc()
+}
+
+// Sampled implements logger.MetricsRecorder.
+func (m *Metrics) Sampled(level logger.Level) {
+	m.sampled.WithLabelValues(level.String()).Inc()
+}
+
+// Sink implements logger.MetricsRecorder.
+func (m *Metrics) Sink(path string) logger.SinkMetrics {
+	return &sinkMetrics{
+		bytes:       m.bytes.WithLabelValues(path),
+		writeErrors: m.errors.WithLabelValues(path, "write"),
+		syncErrors:  m.errors.WithLabelValues(path, "sync"),
+	}
+}
+
+type sinkMetrics struct {
+	bytes       prometheus.Counter
+	writeErrors prometheus.Counter
+	syncErrors  prometheus.Counter
+}
+
+func (s *sinkMetrics) Written(n int, err error) {
+	s.bytes.Add(float64(n))
+	if err != nil {
+		s.writeErrors.Inc()
+	}
+}
+
+func (s *sinkMetrics) Synced(err error) {
+	if err != nil {
+		s.syncErrors.Inc()
+	}
+}
@@ -0,0 +1,39 @@
+package promlog
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/costa92/logger"
+	"github.com/costa92/logger/logtest"
+)
+
+func TestMetrics(t *testing.T) {
+	metrics := New("test")
+	opts := logger.NewOptions()
+	opts.Metrics = metrics
+	l, output := logtest.New(t, opts)
+	path := opts.OutputPaths[0]
+	for i := 0; i < 150; i++ {
+		l.Infow("metrics message")
+	}
+	l.WithName("worker").Warnw("named message")
+	out := output()
+
+	if got := testutil.ToFloat64(metrics.entries.WithLabelValues("info", "")); got != 100 {
+		t.Errorf("expected 100 info entries, got %v", got)
+	}
+	if got := testutil.ToFloat64(metrics.entries.WithLabelValues("warn", "worker")); got != 1 {
+		t.Errorf("expected 1 warn entry of worker, got %v", got)
+	}
+	if got := testutil.ToFloat64(metrics.sampled.WithLabelValues("info")); got != 50 {
+		t.Errorf("expected 50 sampled entries, got %v", got)
+	}
+	if got := testutil.ToFloat64(metrics.bytes.WithLabelValues(path)); got != float64(len(out)) {
+		t.Errorf("expected %d bytes, got %v", len(out), got)
+	}
+	if n := testutil.CollectAndCount(metrics); n == 0 {
+		t.Error("metrics collector is empty")
+	}
+}
@@ -0,0 +1,278 @@
+package logger
+
+import (
+	"container/list"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	rateLimitByLogger = "logger"
+	rateLimitByLevel  = "level"
+	rateLimitByField  = "field"
+
+	defaultRateLimitNoticeInterval = 10 * time.Second
+	defaultRateLimitMaxKeys        = 10000
+)
+
+// RateLimitOptions limits the entries per second of every key with a token
+// bucket, so that a single noisy logger, level or tenant cannot drown the
+// others. Entries above the limit are dropped and a notice with the number
+// of dropped entries of the key is logged periodically.
+type RateLimitOptions struct {
+	// By is logger, level or field and enables rate limiting. The limit can
+	// then be changed at runtime with SetRateLimit.
+	By string `json:"by" yaml:"by" mapstructure:"by"`
+	// Field is the field keying the limit when By is field, e.g. tenant_id.
+	// Entries without the field share one bucket.
+	Field string `json:"field" yaml:"field" mapstructure:"field"`
+	// Rate is the number of entries per second of every key, zero disables
+	// the limit.
+	Rate float64 `json:"rate" yaml:"rate" mapstructure:"rate"`
+	// Burst is the number of entries a key may log at once.
+	Burst int `json:"burst" yaml:"burst" mapstructure:"burst"`
+	// NoticeInterval is the period of the rate limited notices.
+	NoticeInterval time.Duration `json:"notice-interval" yaml:"notice-interval" mapstructure:"notice-interval"`
+	// MaxKeys bounds the number of buckets kept in memory, the least recently
+	// used bucket is forgotten first and its drops are noticed right away.
+	MaxKeys int `json:"max-keys" yaml:"max-keys" mapstructure:"max-keys"`
+}
+
+// Validate checks the key of the rate limit.
+func (o *RateLimitOptions) Validate() []error {
+	var errs []error
+	switch o.By {
+	case "", rateLimitByLogger, rateLimitByLevel:
+	case rateLimitByField:
+		if o.Field == "" {
+			errs = append(errs, fmt.Errorf("rate limit by field requires a field name"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("not a valid rate limit key: %q", o.By))
+	}
+	if o.Rate < 0 || o.Burst < 0 {
+		errs = append(errs, fmt.Errorf("rate limit rate and burst must not be negative"))
+	}
+	return errs
+}
+
+type tokenBucket struct {
+	key     string
+	tokens  float64
+	last    time.Time
+	dropped int
+}
+
+// rateLimitState holds the buckets shared by a rateLimitCore and all cores
+// derived with With.
+type rateLimitState struct {
+	mu       sync.Mutex
+	by       string
+	field    string
+	rate     float64
+	burst    int
+	interval time.Duration
+	maxKeys  int
+	buckets  map[string]*list.Element
+	lru      *list.List
+	timer    *time.Timer
+	core     zapcore.Core
+	errOut   zapcore.WriteSyncer
+}
+
+// rateLimitCore drops the entries of a key above its rate. Like dedupCore it
+// writes through the wrapped core with a check.
+type rateLimitCore struct {
+	zapcore.Core
+	// key is the value of the field from With, when limiting by field.
+	key   string
+	state *rateLimitState
+}
+
+func newRateLimitCore(core zapcore.Core, opts RateLimitOptions, errOut zapcore.WriteSyncer) (zapcore.Core, *rateLimitState) {
+	if opts.By == "" {
+		return core, nil
+	}
+	state := &rateLimitState{
+		by:       opts.By,
+		field:    opts.Field,
+		interval: opts.NoticeInterval,
+		maxKeys:  opts.MaxKeys,
+		buckets:  make(map[string]*list.Element),
+		lru:      list.New(),
+		core:     core,
+		errOut:   errOut,
+	}
+	if state.interval <= 0 {
+		state.interval = defaultRateLimitNoticeInterval
+	}
+	if state.maxKeys <= 0 {
+		state.maxKeys = defaultRateLimitMaxKeys
+	}
+	state.set(opts.Rate, opts.Burst)
+	return &rateLimitCore{Core: core, state: state}, state
+}
+
+func (c *rateLimitCore) With(fields []Field) zapcore.Core {
+	key := c.key
+	if c.state.by == rateLimitByField {
+		if v, ok := fieldValue(fields, c.state.field); ok {
+			key = v
+		}
+	}
+	return &rateLimitCore{Core: c.Core.With(fields), key: key, state: c.state}
+}
+
+func (c *rateLimitCore) Check(ent Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *rateLimitCore) Write(ent Entry, fields []Field) error {
+	if ent.Level >= zapcore.DPanicLevel || c.state.allow(c.keyOf(ent, fields), ent.Time) {
+		writeThrough(c.Core, c.state.errOut, ent, fields)
+	}
+	return nil
+}
+
+func (c *rateLimitCore) Sync() error {
+	c.state.notify()
+	return c.Core.Sync()
+}
+
+func (c *rateLimitCore) keyOf(ent Entry, fields []Field) string {
+	switch c.state.by {
+	case rateLimitByLevel:
+		return ent.Level.String()
+	case rateLimitByField:
+		if v, ok := fieldValue(fields, c.state.field); ok {
+			return v
+		}
+		return c.key
+	default:
+		return ent.LoggerName
+	}
+}
+
+// fieldValue returns the value of the last field named key as a string.
+func fieldValue(fields []Field, key string) (string, bool) {
+	for i := len(fields) - 1; i >= 0; i-- {
+		f := fields[i]
+		if f.Key != key {
+			continue
+		}
+		// nolint: exhaustive
+		switch f.Type {
+		case zapcore.StringType:
+			return f.String, true
+		case zapcore.Int64Type, zapcore.Int32Type, zapcore.Int16Type, zapcore.Int8Type:
+			return strconv.FormatInt(f.Integer, 10), true
+		case zapcore.Uint64Type, zapcore.Uint32Type, zapcore.Uint16Type, zapcore.Uint8Type:
+			return strconv.FormatUint(uint64(f.Integer), 10), true
+		default:
+			if f.Interface != nil {
+				return fmt.Sprint(f.Interface), true
+			}
+			return f.String, true
+		}
+	}
+	return "", false
+}
+
+// set changes the rate and burst of every key.
+func (s *rateLimitState) set(rate float64, burst int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if burst <= 0 {
+		burst = int(rate)
+		if burst < 1 {
+			burst = 1
+		}
+	}
+	s.rate, s.burst = rate, burst
+}
+
+// allow takes a token of the key's bucket, or counts the entry as dropped.
+func (s *rateLimitState) allow(key string, now time.Time) bool {
+	var evicted []*tokenBucket
+	defer func() {
+		for _, b := range evicted {
+			s.writeNotice(b.key, b.dropped)
+		}
+	}()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.rate <= 0 {
+		return true
+	}
+
+	var b *tokenBucket
+	if el, ok := s.buckets[key]; ok {
+		b = el.Value.(*tokenBucket)
+		s.lru.MoveToFront(el)
+	} else {
+		b = &tokenBucket{key: key, tokens: float64(s.burst), last: now}
+		s.buckets[key] = s.lru.PushFront(b)
+		for s.lru.Len() > s.maxKeys {
+			oldest := s.lru.Remove(s.lru.Back()).(*tokenBucket)
+			delete(s.buckets, oldest.key)
+			if oldest.dropped > 0 {
+				evicted = append(evicted, oldest)
+			}
+		}
+	}
+	b.tokens += now.Sub(b.last).Seconds() * s.rate
+	if b.tokens > float64(s.burst) {
+		b.tokens = float64(s.burst)
+	}
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true
+	}
+	b.dropped++
+	if s.timer == nil {
+		s.timer = time.AfterFunc(s.interval, s.notify)
+	}
+	return false
+}
+
+// notify logs one notice per key with the entries dropped since the last one.
+func (s *rateLimitState) notify() {
+	dropped := make(map[string]int)
+	s.mu.Lock()
+	s.timer = nil
+	for key, el := range s.buckets {
+		if b := el.Value.(*tokenBucket); b.dropped > 0 {
+			dropped[key] = b.dropped
+			b.dropped = 0
+		}
+	}
+	s.mu.Unlock()
+
+	for key, n := range dropped {
+		s.writeNotice(key, n)
+	}
+}
+
+// writeNotice logs the number of entries of key dropped since its last
+// notice.
+func (s *rateLimitState) writeNotice(key string, n int) {
+	writeThrough(s.core, s.errOut, Entry{
+		Level:   zapcore.WarnLevel,
+		Time:    time.Now(),
+		Message: fmt.Sprintf("rate limited %d entries", n),
+	}, []Field{
+		zap.String("rate_limit_by", s.by),
+		zap.String("rate_limit_key", key),
+		zap.Int("dropped", n),
+	})
+}
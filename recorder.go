@@ -0,0 +1,222 @@
+package logger
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	recorderScopeRequest = "request"
+	recorderScopeGlobal  = "global"
+
+	defaultRecorderMaxRequests = 1000
+)
+
+// FlightRecorderOptions keeps the last entries below the logger level in
+// memory instead of dropping them, and writes them when an error is logged,
+// so that production runs at info level still show the debug context of a
+// failure.
+type FlightRecorderOptions struct {
+	// Size is the number of entries kept per request, zero disables it.
+	Size int `json:"size" yaml:"size" mapstructure:"size"`
+	// Level is the lowest level kept, debug by default.
+	Level string `json:"level" yaml:"level" mapstructure:"level"`
+	// Scope is request or global. With request the entries are kept per
+	// request id, the X-Request-ID field added by L(ctx), and an error only
+	// writes the entries of its own request. With global an error writes
+	// all kept entries.
+	Scope string `json:"scope" yaml:"scope" mapstructure:"scope"`
+	// MaxRequests bounds the number of requests with kept entries, the least
+	// recently active request is forgotten first.
+	MaxRequests int `json:"max-requests" yaml:"max-requests" mapstructure:"max-requests"`
+}
+
+// Validate checks the level and the scope of the flight recorder.
+func (o *FlightRecorderOptions) Validate() []error {
+	var errs []error
+	if o.Level != "" {
+		var lvl zapcore.Level
+		if err := lvl.UnmarshalText([]byte(o.Level)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	switch o.Scope {
+	case "", recorderScopeRequest, recorderScopeGlobal:
+	default:
+		errs = append(errs, fmt.Errorf("not a valid flight recorder scope: %q", o.Scope))
+	}
+	return errs
+}
+
+// level returns the lowest level kept.
+func (o *FlightRecorderOptions) level() zapcore.Level {
+	lvl := zapcore.DebugLevel
+	if o.Level != "" {
+		_ = lvl.UnmarshalText([]byte(o.Level))
+	}
+	return lvl
+}
+
+type recordedEntry struct {
+	ent    Entry
+	fields []Field
+}
+
+// entryRing keeps the last entries of a request.
+type entryRing struct {
+	requestID string
+	entries   []recordedEntry
+	next      int
+	full      bool
+}
+
+func (r *entryRing) add(e recordedEntry) {
+	r.entries[r.next] = e
+	r.next = (r.next + 1) % len(r.entries)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// drain returns the kept entries, oldest first, and empties the ring.
+func (r *entryRing) drain() []recordedEntry {
+	var out []recordedEntry
+	if r.full {
+		out = append(out, r.entries[r.next:]...)
+	}
+	out = append(out, r.entries[:r.next]...)
+	for i := range r.entries {
+		r.entries[i] = recordedEntry{}
+	}
+	r.next, r.full = 0, false
+	return out
+}
+
+// recorderState is shared by a recorderCore and all cores derived with With.
+type recorderState struct {
+	mu     sync.Mutex
+	size   int
+	level  zapcore.Level
+	global bool
+	max    int
+	rings  map[string]*list.Element
+	lru    *list.List
+	replay zapcore.Core
+	errOut zapcore.WriteSyncer
+}
+
+// recorderCore enables the levels kept by the flight recorder and keeps the
+// entries the wrapped core would drop. Kept entries are written to replay,
+// the sinks without the logger level, with the fields the recorder saw.
+type recorderCore struct {
+	zapcore.Core
+	fields    []Field
+	requestID string
+	state     *recorderState
+}
+
+func newRecorderCore(core, replay zapcore.Core, opts FlightRecorderOptions, errOut zapcore.WriteSyncer) zapcore.Core {
+	if opts.Size <= 0 {
+		return core
+	}
+	state := &recorderState{
+		size:   opts.Size,
+		level:  opts.level(),
+		global: opts.Scope == recorderScopeGlobal,
+		max:    opts.MaxRequests,
+		rings:  make(map[string]*list.Element),
+		lru:    list.New(),
+		replay: replay,
+		errOut: errOut,
+	}
+	if state.max <= 0 {
+		state.max = defaultRecorderMaxRequests
+	}
+	return &recorderCore{Core: core, state: state}
+}
+
+func (c *recorderCore) Enabled(lvl zapcore.Level) bool {
+	return lvl >= c.state.level || c.Core.Enabled(lvl)
+}
+
+func (c *recorderCore) With(fields []Field) zapcore.Core {
+	requestID := c.requestID
+	if v, ok := fieldValue(fields, KeyRequestID); ok {
+		requestID = v
+	}
+	return &recorderCore{
+		Core:      c.Core.With(fields),
+		fields:    append(c.fields[:len(c.fields):len(c.fields)], fields...),
+		requestID: requestID,
+		state:     c.state,
+	}
+}
+
+func (c *recorderCore) Check(ent Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *recorderCore) Write(ent Entry, fields []Field) error {
+	requestID := c.requestID
+	if v, ok := fieldValue(fields, KeyRequestID); ok {
+		requestID = v
+	}
+	if c.state.global {
+		requestID = ""
+	}
+
+	if !c.Core.Enabled(ent.Level) {
+		all := make([]Field, 0, len(c.fields)+len(fields))
+		all = append(all, c.fields...)
+		all = append(all, fields...)
+		c.state.record(requestID, recordedEntry{ent: ent, fields: all})
+		return nil
+	}
+	if ent.Level >= zapcore.ErrorLevel {
+		for _, e := range c.state.take(requestID) {
+			if err := c.state.replay.Write(e.ent, e.fields); err != nil {
+				fmt.Fprintf(c.state.errOut, "%v flight recorder write error: %v\n", e.ent.Time, err)
+			}
+		}
+	}
+	writeThrough(c.Core, c.state.errOut, ent, fields)
+	return nil
+}
+
+func (s *recorderState) record(requestID string, e recordedEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	el, ok := s.rings[requestID]
+	if ok {
+		s.lru.MoveToFront(el)
+	} else {
+		el = s.lru.PushFront(&entryRing{requestID: requestID, entries: make([]recordedEntry, s.size)})
+		s.rings[requestID] = el
+		for s.lru.Len() > s.max {
+			oldest := s.lru.Remove(s.lru.Back()).(*entryRing)
+			delete(s.rings, oldest.requestID)
+		}
+	}
+	el.Value.(*entryRing).add(e)
+}
+
+// take removes and returns the kept entries of the request.
+func (s *recorderState) take(requestID string) []recordedEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	el, ok := s.rings[requestID]
+	if !ok {
+		return nil
+	}
+	s.lru.Remove(el)
+	delete(s.rings, requestID)
+	return el.Value.(*entryRing).drain()
+}
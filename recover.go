@@ -0,0 +1,85 @@
+package logger
+
+import (
+	"context"
+	"runtime/debug"
+)
+
+const defaultRecoverMessage = "recovered from panic"
+
+// RecoverOptions tells RecoverAndLog and Go how to handle a panic.
+type RecoverOptions struct {
+	// Level of the entry, error by default. At DPanic a development logger
+	// panics again after logging.
+	Level Level
+	// Message of the entry.
+	Message string
+	// Repanic panics again with the recovered value after logging it.
+	Repanic bool
+	// OnPanic is called with the recovered value after logging it, before
+	// a Repanic.
+	OnPanic func(value interface{})
+}
+
+// RecoverAndLog recovers a panic and logs it with its value and stack. It has
+// to be deferred directly:
+//
+//	defer logger.RecoverAndLog(log, nil)
+func RecoverAndLog(l Logger, opts *RecoverOptions) {
+	if value := recover(); value != nil {
+		opts.handle(l, value, debug.Stack())
+	}
+}
+
+// Go runs fn in a goroutine, logging a panic of it with the logger of ctx,
+// the trace id of ctx and its request id.
+func Go(ctx context.Context, fn func(ctx context.Context)) {
+	(&RecoverOptions{}).Go(ctx, fn)
+}
+
+// Go runs fn in a goroutine and handles its panic as o tells.
+func (o *RecoverOptions) Go(ctx context.Context, fn func(ctx context.Context)) {
+	go func() {
+		defer func() {
+			if value := recover(); value != nil {
+				o.handle(contextLogger(ctx), value, debug.Stack())
+			}
+		}()
+		fn(ctx)
+	}()
+}
+
+func (o *RecoverOptions) handle(l Logger, value interface{}, stack []byte) {
+	if o == nil {
+		o = &RecoverOptions{}
+	}
+	level, msg := o.Level, o.Message
+	if level == InfoLevel {
+		// the zero value, a recovered panic is never logged at info
+		level = ErrorLevel
+	}
+	if msg == "" {
+		msg = defaultRecoverMessage
+	}
+	l.Logw(level, msg, "panic", value, "stack", string(stack))
+	if o.OnPanic != nil {
+		o.OnPanic(value)
+	}
+	if o.Repanic {
+		panic(value)
+	}
+}
+
+// contextLogger returns the logger of ctx bound to ctx for its trace id. The
+// logger stored in ctx by the middlewares already has the request id, the
+// global logger used without one gets the request id of ctx.
+func contextLogger(ctx context.Context) Logger {
+	if l, ok := ctx.Value(logContextKey).(Logger); ok {
+		return l.Ctx(ctx)
+	}
+	l := std.Ctx(ctx)
+	if requestID := ctx.Value(KeyRequestID); requestID != nil {
+		l = l.With(KeyRequestID, requestID)
+	}
+	return l
+}
@@ -0,0 +1,264 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// Schema names a structured layout expected by a log backend. It decides
+// the keys written by the encoder, how levels and times are encoded and
+// under which keys trace correlation fields are added.
+type Schema string
+
+const (
+	// SchemaDefault is the layout this package has always produced.
+	SchemaDefault Schema = ""
+	// SchemaECS follows the Elastic Common Schema used by Elasticsearch.
+	SchemaECS Schema = "ecs"
+	// SchemaGELF follows the Graylog Extended Log Format 1.1.
+	SchemaGELF Schema = "gelf"
+	// SchemaGCP follows the structured logging format of Google Cloud Logging.
+	SchemaGCP Schema = "gcp"
+)
+
+const gelfVersion = "1.1"
+
+var hostname, _ = os.Hostname()
+
+// schemaPreset holds everything a Schema changes in the logger output.
+type schemaPreset struct {
+	messageKey    string
+	levelKey      string
+	timeKey       string
+	nameKey       string
+	callerKey     string
+	stacktraceKey string
+	encodeLevel   zapcore.LevelEncoder
+	// colorLevel replaces encodeLevel for colored console output, schemas
+	// read by a backend leave it nil and never emit ANSI escapes.
+	colorLevel zapcore.LevelEncoder
+	encodeTime zapcore.TimeEncoder
+	// originCaller writes the caller as the ECS log.origin object.
+	originCaller bool
+	traceIDKey   string
+	spanIDKey    string
+	fields       map[string]interface{}
+}
+
+// is reports whether s names schema, in any case.
+func (s Schema) is(schema Schema) bool {
+	return Schema(strings.ToLower(string(s))) == schema
+}
+
+func (s Schema) preset() (schemaPreset, error) {
+	switch Schema(strings.ToLower(string(s))) {
+	case SchemaDefault, "default":
+		return schemaPreset{
+			messageKey:    "message",
+			levelKey:      "level",
+			timeKey:       "timestamp",
+			nameKey:       "logger",
+			callerKey:     "caller",
+			stacktraceKey: "stacktrace",
+			encodeLevel:   zapcore.CapitalLevelEncoder,
+			colorLevel:    zapcore.CapitalColorLevelEncoder,
+			encodeTime:    timeEncoder,
+			traceIDKey:    KeyTraceID,
+		}, nil
+	case SchemaECS:
+		return schemaPreset{
+			messageKey:    "message",
+			levelKey:      "log.level",
+			timeKey:       "@timestamp",
+			nameKey:       "log.logger",
+			callerKey:     "log.origin",
+			originCaller:  true,
+			stacktraceKey: "error.stack_trace",
+			encodeLevel:   zapcore.LowercaseLevelEncoder,
+			encodeTime:    zapcore.ISO8601TimeEncoder,
+			traceIDKey:    "trace.id",
+			spanIDKey:     "span.id",
+			fields:        map[string]interface{}{"ecs.version": "1.6.0"},
+		}, nil
+	case SchemaGELF:
+		// GELF reserves unprefixed keys, additional fields must start with "_",
+		// gelfCore prefixes the keys of the fields.
+		return schemaPreset{
+			messageKey:    "short_message",
+			levelKey:      "level",
+			timeKey:       "timestamp",
+			nameKey:       "_logger",
+			callerKey:     "_caller",
+			stacktraceKey: "full_message",
+			encodeLevel:   syslogLevelEncoder,
+			encodeTime:    zapcore.EpochTimeEncoder,
+			traceIDKey:    "_trace_id",
+			spanIDKey:     "_span_id",
+			fields:        map[string]interface{}{"version": gelfVersion, "host": hostname},
+		}, nil
+	case SchemaGCP:
+		return schemaPreset{
+			messageKey:    "message",
+			levelKey:      "severity",
+			timeKey:       "time",
+			nameKey:       "logger",
+			callerKey:     "caller",
+			stacktraceKey: "stack_trace",
+			encodeLevel:   gcpLevelEncoder,
+			encodeTime:    zapcore.RFC3339NanoTimeEncoder,
+			traceIDKey:    "logging.googleapis.com/trace",
+			spanIDKey:     "logging.googleapis.com/spanId",
+		}, nil
+	default:
+		return schemaPreset{}, fmt.Errorf("not a valid log schema: %q", s)
+	}
+}
+
+// originCallerEncoder writes the caller as the log.origin object of ECS,
+// with the file name, its line and the function apart. Encoders that cannot
+// nest an object, the console one, get the caller of enc.
+func originCallerEncoder(full bool, enc zapcore.CallerEncoder) zapcore.CallerEncoder {
+	return func(caller zapcore.EntryCaller, pae zapcore.PrimitiveArrayEncoder) {
+		arr, ok := pae.(zapcore.ArrayEncoder)
+		if _, nested := pae.(zapcore.ObjectEncoder); !ok || !nested {
+			enc(caller, pae)
+			return
+		}
+		origin := logOrigin{file: caller.File, line: caller.Line, function: caller.Function}
+		if !full {
+			origin.file = strings.TrimSuffix(caller.TrimmedPath(), ":"+strconv.Itoa(caller.Line))
+		}
+		_ = arr.AppendObject(origin)
+	}
+}
+
+type logOrigin struct {
+	file     string
+	line     int
+	function string
+}
+
+func (o logOrigin) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	_ = enc.AddObject("file", zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+		enc.AddString("name", o.file)
+		enc.AddInt("line", o.line)
+		return nil
+	}))
+	if o.function != "" {
+		enc.AddString("function", o.function)
+	}
+	return nil
+}
+
+// syslogSeverity maps a level to the RFC 5424 numeric severity.
+func syslogSeverity(l zapcore.Level) int {
+	switch l {
+	case zapcore.DebugLevel:
+		return 7
+	case zapcore.InfoLevel:
+		return 6
+	case zapcore.WarnLevel:
+		return 4
+	case zapcore.ErrorLevel:
+		return 3
+	case zapcore.DPanicLevel:
+		return 2
+	case zapcore.PanicLevel:
+		return 1
+	case zapcore.FatalLevel:
+		return 0
+	default:
+		return 6
+	}
+}
+
+func syslogLevelEncoder(l zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
+	enc.AppendInt(syslogSeverity(l))
+}
+
+// gcpLevelEncoder writes the LogSeverity names understood by Cloud Logging.
+func gcpLevelEncoder(l zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
+	switch l {
+	case zapcore.DebugLevel:
+		enc.AppendString("DEBUG")
+	case zapcore.InfoLevel:
+		enc.AppendString("INFO")
+	case zapcore.WarnLevel:
+		enc.AppendString("WARNING")
+	case zapcore.ErrorLevel:
+		enc.AppendString("ERROR")
+	case zapcore.DPanicLevel:
+		enc.AppendString("CRITICAL")
+	case zapcore.PanicLevel:
+		enc.AppendString("ALERT")
+	case zapcore.FatalLevel:
+		enc.AppendString("EMERGENCY")
+	default:
+		enc.AppendString("DEFAULT")
+	}
+}
+
+// gelfReserved are the GELF keys, written as is by gelfCore.
+var gelfReserved = map[string]struct{}{
+	"version": {}, "host": {}, "short_message": {}, "full_message": {},
+	"timestamp": {}, "level": {}, "facility": {}, "line": {}, "file": {},
+}
+
+// gelfCore prefixes with "_" the keys of the fields that are not reserved by
+// GELF, Graylog drops additional fields without the prefix.
+type gelfCore struct {
+	zapcore.Core
+}
+
+func newGELFCore(core zapcore.Core, schema Schema) zapcore.Core {
+	if !schema.is(SchemaGELF) {
+		return core
+	}
+	return &gelfCore{Core: core}
+}
+
+func (c *gelfCore) With(fields []zapcore.Field) zapcore.Core {
+	return &gelfCore{Core: c.Core.With(gelfFields(fields))}
+}
+
+func (c *gelfCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *gelfCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	return c.Core.Write(ent, gelfFields(fields))
+}
+
+// gelfFields returns fields with their keys prefixed, the slice is only
+// copied when a key needs the prefix.
+func gelfFields(fields []zapcore.Field) []zapcore.Field {
+	for i := range fields {
+		if !gelfNeedsPrefix(fields[i].Key) {
+			continue
+		}
+		prefixed := make([]zapcore.Field, len(fields))
+		copy(prefixed, fields)
+		for j := i; j < len(prefixed); j++ {
+			if gelfNeedsPrefix(prefixed[j].Key) {
+				prefixed[j].Key = "_" + prefixed[j].Key
+			}
+		}
+		return prefixed
+	}
+	return fields
+}
+
+func gelfNeedsPrefix(key string) bool {
+	if key == "" || strings.HasPrefix(key, "_") {
+		return false
+	}
+	_, reserved := gelfReserved[key]
+	return !reserved
+}
@@ -0,0 +1,107 @@
+package logger
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"go.uber.org/multierr"
+	"go.uber.org/zap/zapcore"
+)
+
+// exitHook ends the process after a fatal entry with Options.ExitFunc instead
+// of os.Exit, so that tests can catch Fatal.
+type exitHook func(code int)
+
+func (h exitHook) OnWrite(*zapcore.CheckedEntry, []zapcore.Field) {
+	h(1)
+}
+
+// exit ends the process with the exit function of the logger.
+func (l *logger) exit(code int) {
+	if l.options != nil && l.options.ExitFunc != nil {
+		l.options.ExitFunc(code)
+		return
+	}
+	os.Exit(code)
+}
+
+// Shutdown flushes the logger, the summaries of dedup and the notices of the
+// rate limit included, and closes the outputs it opened. It returns when the
+// flush is done or ctx is, whichever comes first. The logger must not be used
+// afterwards.
+func (l *logger) Shutdown(ctx context.Context) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- syncErrors(l.zapLogger.Sync())
+	}()
+	select {
+	case err := <-done:
+		if l.controls != nil {
+			l.controls.close()
+		}
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Shutdown flushes and closes the global logger.
+func Shutdown(ctx context.Context) error {
+	return std.Shutdown(ctx)
+}
+
+// syncErrors drops the errors of syncing outputs that cannot be synced, such
+// as stdout attached to a terminal.
+func syncErrors(err error) error {
+	var errs []error
+	for _, e := range multierr.Errors(err) {
+		if !isIgnorableSyncError(e) {
+			errs = append(errs, e)
+		}
+	}
+	return multierr.Combine(errs...)
+}
+
+// HandleSignals logs the reception of SIGINT or SIGTERM, or of the given
+// signals, shuts the logger down within timeout and exits with the code of
+// the signal. It is meant for programs without a shutdown of their own, the
+// others call Shutdown at the end of theirs. stop ends the handling.
+func (l *logger) HandleSignals(timeout time.Duration, signals ...os.Signal) (stop func()) {
+	if len(signals) == 0 {
+		signals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, signals...)
+	done := make(chan struct{})
+	go l.handleSignals(ch, done, timeout)
+	return func() {
+		signal.Stop(ch)
+		close(done)
+	}
+}
+
+// HandleSignals handles the signals with the global logger.
+func HandleSignals(timeout time.Duration, signals ...os.Signal) (stop func()) {
+	return std.HandleSignals(timeout, signals...)
+}
+
+func (l *logger) handleSignals(ch <-chan os.Signal, done <-chan struct{}, timeout time.Duration) {
+	select {
+	case sig := <-ch:
+		l.Warnw("shutting down", "signal", sig.String())
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		if err := l.Shutdown(ctx); err != nil {
+			os.Stderr.WriteString("logger shutdown: " + err.Error() + "\n")
+		}
+		code := 1
+		if s, ok := sig.(syscall.Signal); ok {
+			code = 128 + int(s)
+		}
+		l.exit(code)
+	case <-done:
+	}
+}
@@ -0,0 +1,109 @@
+package logger
+
+import (
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// SinkOptions describes one output of the logger. When Options.Sinks is set
+// it replaces OutputPaths, and every sink has its own format, level range and
+// color mode, for example console on stdout at debug, json to a file at info
+// and error logs to a separate file.
+type SinkOptions struct {
+	// Path is a zap output path, a file path, stdout, stderr or a registered
+	// sink URL.
+	Path string `json:"path" yaml:"path" mapstructure:"path"`
+	// Format defaults to Options.Format.
+	Format string `json:"format" yaml:"format" mapstructure:"format"`
+	// MinLevel defaults to Options.Level, which can also be changed at runtime.
+	MinLevel string `json:"min-level" yaml:"min-level" mapstructure:"min-level"`
+	// MaxLevel is the highest level written to the sink, empty means no bound.
+	MaxLevel string `json:"max-level" yaml:"max-level" mapstructure:"max-level"`
+	// Color defaults to the color mode of Options.
+	Color string `json:"color" yaml:"color" mapstructure:"color"`
+}
+
+// Validate checks the path, format, levels and color mode of the sink.
+func (s *SinkOptions) Validate() []error {
+	var errs []error
+	if s.Path == "" {
+		errs = append(errs, fmt.Errorf("sink path is required"))
+	}
+	if _, _, err := syslogConfigOf(s.Path); err != nil {
+		errs = append(errs, err)
+	}
+	switch strings.ToLower(s.Format) {
+	case "", consoleFormat, jsonFormat, prettyFormat:
+	default:
+		errs = append(errs, fmt.Errorf("not a valid log format of sink %q: %q", s.Path, s.Format))
+	}
+	for _, lvl := range []string{s.MinLevel, s.MaxLevel} {
+		if lvl == "" {
+			continue
+		}
+		var zapLevel zapcore.Level
+		if err := zapLevel.UnmarshalText([]byte(lvl)); err != nil {
+			errs = append(errs, fmt.Errorf("sink %q: %w", s.Path, err))
+		}
+	}
+	switch s.Color {
+	case "", colorAuto, colorAlways, colorNever:
+	default:
+		errs = append(errs, fmt.Errorf("not a valid color mode of sink %q: %q", s.Path, s.Color))
+	}
+	return errs
+}
+
+// levelRange enables the levels from min to max. A nil min defers to the
+// level of the logger.
+type levelRange struct {
+	min zapcore.LevelEnabler
+	max zapcore.Level
+}
+
+func (r levelRange) Enabled(lvl zapcore.Level) bool {
+	return r.min.Enabled(lvl) && lvl <= r.max
+}
+
+// outputSinks returns the configured sinks, or one sink per output path
+// sharing the format and level of the options.
+func (o *Options) outputSinks(level zapcore.LevelEnabler) []outputSink {
+	mode := o.colorMode()
+	if len(o.Sinks) == 0 {
+		sinks := make([]outputSink, 0, len(o.OutputPaths))
+		for _, path := range o.OutputPaths {
+			sinks = append(sinks, outputSink{
+				path:    path,
+				format:  o.Format,
+				color:   o.Format != jsonFormat && colorEnabled(mode, path),
+				enabler: level,
+			})
+		}
+		return sinks
+	}
+
+	sinks := make([]outputSink, 0, len(o.Sinks))
+	for _, s := range o.Sinks {
+		format := strings.ToLower(firstNonEmpty(s.Format, o.Format))
+		sinkMode := firstNonEmpty(s.Color, mode)
+		r := levelRange{min: level, max: zapcore.FatalLevel}
+		if s.MinLevel != "" {
+			var minLevel zapcore.Level
+			if err := minLevel.UnmarshalText([]byte(s.MinLevel)); err == nil {
+				r.min = minLevel
+			}
+		}
+		if s.MaxLevel != "" {
+			_ = r.max.UnmarshalText([]byte(s.MaxLevel))
+		}
+		sinks = append(sinks, outputSink{
+			path:    s.Path,
+			format:  format,
+			color:   format != jsonFormat && colorEnabled(sinkMode, s.Path),
+			enabler: r,
+		})
+	}
+	return sinks
+}
@@ -0,0 +1,126 @@
+package logger
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"sync"
+)
+
+// LevelWriter is an io.Writer logging every line written to it as an entry
+// of a Logger. Lines are split on newlines, which are trimmed, and a line
+// written in several parts is logged once complete.
+type LevelWriter struct {
+	// ParsePrefix reads a level prefix such as [ERROR], ERROR: or [warn] from
+	// the start of every line and logs the rest of the line at that level.
+	// Panic and fatal prefixes are logged at error, a third party library
+	// must not stop the process through its log lines.
+	ParsePrefix bool
+
+	mu     sync.Mutex
+	logger Logger
+	level  Level
+	buf    []byte
+}
+
+// Writer returns a writer logging to l at level, reporting the caller of
+// Write as the caller of the entries.
+func Writer(l Logger, level Level) *LevelWriter {
+	return &LevelWriter{logger: l.WithSkip(1), level: level}
+}
+
+// StdLogger returns a standard library logger, e.g. for http.Server.ErrorLog,
+// logging to l at level. The entries report the caller of the *log.Logger.
+func StdLogger(l Logger, level Level) *log.Logger {
+	return stdLogger(l, level, false)
+}
+
+// PrefixStdLogger returns a StdLogger with ParsePrefix set, logging the lines
+// without a level prefix at level.
+func PrefixStdLogger(l Logger, level Level) *log.Logger {
+	return stdLogger(l, level, true)
+}
+
+func stdLogger(l Logger, level Level, parsePrefix bool) *log.Logger {
+	// Write is called through log.(*Logger).Output and the print method
+	return log.New(&LevelWriter{ParsePrefix: parsePrefix, logger: l.WithSkip(3), level: level}, "", 0)
+}
+
+func (w *LevelWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf = append(w.buf, p...)
+	rest := w.buf
+	for {
+		i := bytes.IndexByte(rest, '\n')
+		if i < 0 {
+			break
+		}
+		line := strings.TrimRight(string(rest[:i]), "\r")
+		rest = rest[i+1:]
+		if line == "" {
+			continue
+		}
+		level, msg := w.levelOf(line)
+		w.logger.Log(level, msg)
+	}
+	w.buf = append(w.buf[:0], rest...)
+	return len(p), nil
+}
+
+// Flush logs the line written without a trailing newline, if any.
+func (w *LevelWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	line := strings.TrimRight(string(w.buf), "\r")
+	w.buf = w.buf[:0]
+	if line != "" {
+		level, msg := w.levelOf(line)
+		w.logger.Log(level, msg)
+	}
+}
+
+// levelOf returns the level and message of a line, parsing its prefix when
+// ParsePrefix is set.
+func (w *LevelWriter) levelOf(line string) (Level, string) {
+	if !w.ParsePrefix {
+		return w.level, line
+	}
+	var prefix, rest string
+	switch {
+	case strings.HasPrefix(line, "["):
+		end := strings.IndexByte(line, ']')
+		if end < 0 {
+			return w.level, line
+		}
+		prefix, rest = line[1:end], line[end+1:]
+	default:
+		end := strings.IndexAny(line, ": ")
+		if end < 0 || line[end] != ':' {
+			return w.level, line
+		}
+		prefix, rest = line[:end], line[end+1:]
+	}
+	level, ok := prefixLevel(prefix)
+	if !ok {
+		return w.level, line
+	}
+	return level, strings.TrimLeft(rest, " \t")
+}
+
+func prefixLevel(prefix string) (Level, bool) {
+	switch strings.ToLower(prefix) {
+	case "debug", "trace":
+		return DebugLevel, true
+	case "info", "notice":
+		return InfoLevel, true
+	case "warn", "warning":
+		return WarnLevel, true
+	case "error", "err", "crit", "critical", "dpanic", "panic", "fatal":
+		return ErrorLevel, true
+	default:
+		return InfoLevel, false
+	}
+}
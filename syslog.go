@@ -0,0 +1,375 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// The syslog output paths:
+//
+//	syslog://host:514?facility=local0    RFC 5424 over UDP
+//	syslog+tcp://host:514                RFC 5424 over TCP, octet counted
+//	unixgram:///dev/log                  RFC 3164 to the local daemon
+//
+// The query takes a facility, user by default, a tag, the name of the
+// program by default, and rfc=3164 or rfc=5424 to change the message format.
+const (
+	syslogScheme    = "syslog"
+	syslogTCPScheme = "syslog+tcp"
+	unixgramScheme  = "unixgram"
+
+	rfc3164 = "3164"
+	rfc5424 = "5424"
+
+	defaultSyslogPort = "514"
+	syslogTimeout     = 5 * time.Second
+	syslogMinBackoff  = 100 * time.Millisecond
+	syslogMaxBackoff  = 30 * time.Second
+	// syslogSDID is the id of the structured data element holding the fields,
+	// under the private enterprise number reserved for documentation.
+	syslogSDID = "fields@32473"
+)
+
+// errSyslogDisconnected is returned by the writes dropped while the sink waits
+// to dial the daemon again.
+var errSyslogDisconnected = errors.New("syslog daemon unreachable, dropping entries until it is dialed again")
+
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5, "lpr": 6, "news": 7,
+	"uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+func init() {
+	for _, scheme := range []string{syslogScheme, syslogTCPScheme, unixgramScheme} {
+		if err := zap.RegisterSink(scheme, newSyslogSink); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// syslogConfig is the destination and message format of a syslog path.
+type syslogConfig struct {
+	network  string
+	addr     string
+	facility int
+	rfc      string
+	tag      string
+}
+
+// syslogConfigOf returns the config of path when it is a syslog path.
+func syslogConfigOf(path string) (*syslogConfig, bool, error) {
+	u, err := url.Parse(path)
+	if err != nil {
+		return nil, false, nil
+	}
+	switch u.Scheme {
+	case syslogScheme, syslogTCPScheme, unixgramScheme:
+		cfg, err := parseSyslogURL(u)
+		return cfg, true, err
+	default:
+		return nil, false, nil
+	}
+}
+
+func parseSyslogURL(u *url.URL) (*syslogConfig, error) {
+	cfg := &syslogConfig{rfc: rfc5424, tag: filepath.Base(os.Args[0])}
+	switch u.Scheme {
+	case unixgramScheme:
+		cfg.network, cfg.addr, cfg.rfc = "unixgram", u.Path, rfc3164
+		if cfg.addr == "" {
+			return nil, fmt.Errorf("syslog path %q: socket path is required", u)
+		}
+	default:
+		cfg.network, cfg.addr = "udp", u.Host
+		if u.Scheme == syslogTCPScheme {
+			cfg.network = "tcp"
+		}
+		if u.Hostname() == "" {
+			return nil, fmt.Errorf("syslog path %q: host is required", u)
+		}
+		if u.Port() == "" {
+			cfg.addr = net.JoinHostPort(u.Hostname(), defaultSyslogPort)
+		}
+	}
+
+	query := u.Query()
+	facility := firstNonEmpty(query.Get("facility"), "user")
+	var ok bool
+	if cfg.facility, ok = syslogFacilities[strings.ToLower(facility)]; !ok {
+		return nil, fmt.Errorf("syslog path %q: not a valid facility: %q", u, facility)
+	}
+	if rfc := query.Get("rfc"); rfc != "" {
+		if rfc != rfc3164 && rfc != rfc5424 {
+			return nil, fmt.Errorf("syslog path %q: not a valid rfc: %q", u, rfc)
+		}
+		cfg.rfc = rfc
+	}
+	cfg.tag = firstNonEmpty(query.Get("tag"), cfg.tag)
+	return cfg, nil
+}
+
+// syslogSink sends every write as one message to a syslog daemon. It dials on
+// the first write and again after a failed one, so that the logger survives a
+// restart of the daemon. After a failed dial the writes are dropped until the
+// backoff is over, an unreachable daemon does not block every entry for the
+// dial timeout. The messages are framed by syslogCore.
+type syslogSink struct {
+	network string
+	addr    string
+
+	mu   sync.Mutex
+	conn net.Conn
+	// retryAt is the earliest time of the next dial, backoff the wait after
+	// the last failure.
+	retryAt time.Time
+	backoff time.Duration
+}
+
+func newSyslogSink(u *url.URL) (zap.Sink, error) {
+	cfg, err := parseSyslogURL(u)
+	if err != nil {
+		return nil, err
+	}
+	return &syslogSink{network: cfg.network, addr: cfg.addr}, nil
+}
+
+func (s *syslogSink) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	msg := bytes.TrimRight(p, "\n")
+	if s.network == "tcp" {
+		// octet counting framing of RFC 6587
+		msg = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+	}
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if s.conn == nil {
+			if time.Now().Before(s.retryAt) {
+				return 0, errSyslogDisconnected
+			}
+			if s.conn, err = net.DialTimeout(s.network, s.addr, syslogTimeout); err != nil {
+				s.conn = nil
+				s.backOff()
+				return 0, err
+			}
+		}
+		_ = s.conn.SetWriteDeadline(time.Now().Add(syslogTimeout))
+		if _, err = s.conn.Write(msg); err == nil {
+			s.backoff = 0
+			return len(p), nil
+		}
+		s.conn.Close()
+		s.conn = nil
+	}
+	s.backOff()
+	return 0, err
+}
+
+// backOff doubles the wait before the next dial, up to syslogMaxBackoff.
+func (s *syslogSink) backOff() {
+	s.backoff *= 2
+	if s.backoff < syslogMinBackoff {
+		s.backoff = syslogMinBackoff
+	}
+	if s.backoff > syslogMaxBackoff {
+		s.backoff = syslogMaxBackoff
+	}
+	s.retryAt = time.Now().Add(s.backoff)
+}
+
+func (s *syslogSink) Sync() error {
+	return nil
+}
+
+func (s *syslogSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// syslogCore writes the entries encoded by enc as syslog messages, with the
+// severity of their level and, in RFC 5424, their fields as structured data.
+type syslogCore struct {
+	zapcore.LevelEnabler
+	enc    zapcore.Encoder
+	out    zapcore.WriteSyncer
+	cfg    *syslogConfig
+	fields []zapcore.Field
+}
+
+func newSyslogCore(cfg *syslogConfig, enc zapcore.Encoder, out zapcore.WriteSyncer, enab zapcore.LevelEnabler) zapcore.Core {
+	return &syslogCore{LevelEnabler: enab, enc: enc, out: out, cfg: cfg}
+}
+
+func (c *syslogCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.enc = c.enc.Clone()
+	for _, f := range fields {
+		f.AddTo(clone.enc)
+	}
+	clone.fields = append(c.fields[:len(c.fields):len(c.fields)], fields...)
+	return &clone
+}
+
+func (c *syslogCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *syslogCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	buf, err := c.enc.EncodeEntry(ent, fields)
+	if err != nil {
+		return err
+	}
+	defer buf.Free()
+
+	msg := c.header(ent, fields)
+	msg = append(msg, bytes.TrimRight(buf.Bytes(), "\n")...)
+	if _, err := c.out.Write(msg); err != nil {
+		return err
+	}
+	if ent.Level > zapcore.ErrorLevel {
+		// the process may be about to exit
+		return c.Sync()
+	}
+	return nil
+}
+
+func (c *syslogCore) Sync() error {
+	return c.out.Sync()
+}
+
+// header returns the start of the message up to the MSG part.
+func (c *syslogCore) header(ent zapcore.Entry, fields []zapcore.Field) []byte {
+	pri := c.cfg.facility*8 + syslogSeverity(ent.Level)
+	var b bytes.Buffer
+	if c.cfg.rfc == rfc3164 {
+		fmt.Fprintf(&b, "<%d>%s ", pri, ent.Time.Format(time.Stamp))
+		if c.cfg.network != "unixgram" {
+			// the local daemon adds the hostname itself
+			b.WriteString(hostname + " ")
+		}
+		fmt.Fprintf(&b, "%s[%d]: ", c.cfg.tag, os.Getpid())
+		return b.Bytes()
+	}
+	fmt.Fprintf(&b, "<%d>1 %s %s %s %d %s ",
+		pri,
+		ent.Time.Format("2006-01-02T15:04:05.000000Z07:00"),
+		syslogName(hostname, 255),
+		syslogName(c.cfg.tag, 48),
+		os.Getpid(),
+		syslogName(ent.LoggerName, 32),
+	)
+	c.writeStructuredData(&b, fields)
+	b.WriteByte(' ')
+	return b.Bytes()
+}
+
+// writeStructuredData writes the fields of the core and of the entry as the
+// parameters of one SD element, or "-" without fields.
+func (c *syslogCore) writeStructuredData(b *bytes.Buffer, fields []zapcore.Field) {
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range c.fields {
+		f.AddTo(enc)
+	}
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+	if len(enc.Fields) == 0 {
+		b.WriteByte('-')
+		return
+	}
+	keys := make([]string, 0, len(enc.Fields))
+	for k := range enc.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	b.WriteString("[" + syslogSDID)
+	for _, k := range keys {
+		name := syslogParamName(k)
+		if name == "" {
+			continue
+		}
+		b.WriteString(" " + name + `="`)
+		syslogParamEscaper.WriteString(b, syslogParamValue(enc.Fields[k]))
+		b.WriteByte('"')
+	}
+	b.WriteByte(']')
+}
+
+// syslogParamEscaper escapes the characters RFC 5424 reserves in a PARAM-VALUE.
+var syslogParamEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+
+func syslogParamValue(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case map[string]interface{}, []interface{}:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(data)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// syslogParamName returns key without the characters an SD-NAME cannot hold,
+// cut to its 32 characters.
+func syslogParamName(key string) string {
+	name := strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' || r == '=' || r == ']' || r == '"' {
+			return -1
+		}
+		return r
+	}, key)
+	if len(name) > 32 {
+		name = name[:32]
+	}
+	return name
+}
+
+// syslogName returns s as a header field of at most max printable characters,
+// "-" when empty.
+func syslogName(s string, max int) string {
+	name := strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' {
+			return -1
+		}
+		return r
+	}, s)
+	if len(name) > max {
+		name = name[:max]
+	}
+	if name == "" {
+		return "-"
+	}
+	return name
+}
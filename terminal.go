@@ -0,0 +1,80 @@
+package logger
+
+import (
+	"bytes"
+	"os"
+	"regexp"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// colorAuto colors the outputs attached to a terminal.
+	colorAuto = "auto"
+	// colorAlways colors every output, including files.
+	colorAlways = "always"
+	// colorNever disables color.
+	colorNever = "never"
+)
+
+// ansiPattern matches the ANSI escape sequences used to color text.
+var ansiPattern = regexp.MustCompile("\x1b\\[[0-9;]*[A-Za-z]")
+
+// isTerminal reports whether f is attached to a character device, which is
+// the case for an interactive terminal and not for pipes or regular files.
+func isTerminal(f *os.File) bool {
+	if f == nil {
+		return false
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// isTerminalPath reports whether the zap output path is a terminal. Only the
+// standard streams are checked, any other path is treated as a file.
+func isTerminalPath(path string) bool {
+	switch path {
+	case "stdout":
+		return isTerminal(os.Stdout)
+	case "stderr":
+		return isTerminal(os.Stderr)
+	default:
+		return false
+	}
+}
+
+// colorEnabled decides whether the output at path is colored in the given
+// color mode.
+func colorEnabled(mode, path string) bool {
+	switch mode {
+	case colorAlways:
+		return true
+	case colorAuto:
+		return isTerminalPath(path)
+	default:
+		return false
+	}
+}
+
+type ansiStripper struct {
+	zapcore.WriteSyncer
+}
+
+// stripANSI removes color escapes from everything written to ws, they may
+// still be part of a message even when the encoder does not add any.
+func stripANSI(ws zapcore.WriteSyncer) zapcore.WriteSyncer {
+	return &ansiStripper{WriteSyncer: ws}
+}
+
+func (s *ansiStripper) Write(p []byte) (int, error) {
+	if bytes.IndexByte(p, 0x1b) < 0 {
+		return s.WriteSyncer.Write(p)
+	}
+	if _, err := s.WriteSyncer.Write(ansiPattern.ReplaceAll(p, nil)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
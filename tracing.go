@@ -16,6 +16,8 @@ import (
 var (
 	logSeverityKey = attribute.Key("log.severity")
 	logMessageKey  = attribute.Key("log.message")
+
+	exceptionChainKey = attribute.Key("exception.chain")
 )
 
 func levelString(lvl zapcore.Level) string {
@@ -70,6 +72,9 @@ func appendField(attrs []attribute.KeyValue, f zapcore.Field) []attribute.KeyVal
 		return append(attrs, attr)
 	case zapcore.ErrorType:
 		err := f.Interface.(error)
+		if isNilError(err) {
+			return append(attrs, attribute.String(f.Key, "<nil>"))
+		}
 		typ := reflect.TypeOf(err).String()
 		attrs = append(attrs, semconv.ExceptionTypeKey.String(typ))
 		attrs = append(attrs, semconv.ExceptionMessageKey.String(err.Error()))
@@ -94,6 +99,9 @@ func appendField(attrs []attribute.KeyValue, f zapcore.Field) []attribute.KeyVal
 		return append(attrs, attr)
 
 	case zapcore.ObjectMarshalerType:
+		if re, ok := f.Interface.(richError); ok {
+			return appendRichError(attrs, re.err)
+		}
 		attr := attribute.String(f.Key+"_error", "otelzap: zapcore.ObjectMarshalerType is not implemented")
 		return append(attrs, attr)
 
@@ -102,3 +110,21 @@ func appendField(attrs []attribute.KeyValue, f zapcore.Field) []attribute.KeyVal
 		return append(attrs, attr)
 	}
 }
+
+// appendRichError adds the same details of an error to the span attributes
+// as richError adds to the log entry.
+func appendRichError(attrs []attribute.KeyValue, err error) []attribute.KeyValue {
+	attrs = append(attrs, semconv.ExceptionTypeKey.String(errorType(err)))
+	attrs = append(attrs, semconv.ExceptionMessageKey.String(err.Error()))
+	if chain := errorChain(err); len(chain) > 0 {
+		messages := make([]string, len(chain))
+		for i, e := range chain {
+			messages[i] = e.Error()
+		}
+		attrs = append(attrs, exceptionChainKey.StringSlice(messages))
+	}
+	if stack := errorStack(err); stack != "" {
+		attrs = append(attrs, semconv.ExceptionStacktraceKey.String(stack))
+	}
+	return attrs
+}
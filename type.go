@@ -7,6 +7,8 @@ import (
 
 type Field = zapcore.Field
 
+type Entry = zapcore.Entry
+
 var (
 	Any         = zap.Any
 	Array       = zap.Array
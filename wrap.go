@@ -10,7 +10,18 @@ func Debug(args ...interface{}) {
 	std.logger.Debug(args...)
 }
 
-func Info(args ...interface{}) {
+// Info logs msg with key-value pairs, as Logger.Info does.
+func Info(msg string, keysAndValues ...interface{}) {
+	msg, keysAndValues = infoArgs(msg, keysAndValues)
+	std.logger.Infow(msg, keysAndValues...)
+}
+
+// Print logs args at info level, joined as fmt.Sprint does.
+//
+// Deprecated: Print is the former Info(args ...interface{}), kept for calls
+// whose first argument is not a message, such as Info(err). Use Info with a
+// message or Infof instead.
+func Print(args ...interface{}) {
 	std.logger.Info(args...)
 }
 
@@ -90,6 +101,11 @@ func Fatalw(msg string, keysAndValues ...interface{}) {
 	std.zapLogger.Sugar().Fatalw(msg, keysAndValues...)
 }
 
+// SetRateLimit changes the rate limit of the global logger.
+func SetRateLimit(rate float64, burst int) bool {
+	return std.SetRateLimit(rate, burst)
+}
+
 func Sync() error {
 	return std.logger.Sync()
 }